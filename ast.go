@@ -15,6 +15,8 @@ const (
 	ndElse
 	ndEnd
 	ndEndOfProgram
+	ndFor
+	ndEmbed
 )
 
 // tree is a component of an abstract syntax tree
@@ -23,6 +25,8 @@ type tree struct {
 	Left  *tree
 	Right *tree
 	Token *token
+	cond  Condition /* for IF/ELIF */
+	loop  *loop     /* for FOR */
 }
 
 // astはトークン列から抽象構文木を生成する。
@@ -30,7 +34,9 @@ type tree struct {
 // program = stmt
 // stmt = 	SQLStmt stmt |
 //			BIND	stmt |
+//			EMBED	stmt |
 //		  	"IF" stmt ("ELLF" stmt)* ("ELSE" stmt)? "END" stmt |
+//			"FOR" stmt "END" stmt |
 //			EndOfProgram
 //
 func ast(tokens []token) (*tree, error) {
@@ -74,6 +80,17 @@ func stmt(tokens []token, index *int) (*tree, error) {
 			Token: &tokens[*index-1],
 		}
 
+		node.Left, err = stmt(tokens, index)
+		if err != nil {
+			return nil, err
+		}
+	} else if consume(tokens, index, tkEmbed) {
+		// Embed stmt
+		node = &tree{
+			Kind:  ndEmbed,
+			Token: &tokens[*index-1],
+		}
+
 		node.Left, err = stmt(tokens, index)
 		if err != nil {
 			return nil, err
@@ -151,6 +168,33 @@ func stmt(tokens []token, index *int) (*tree, error) {
 
 		// どれも一致しなかった
 		return node, nil
+	} else if consume(tokens, index, tkFor) {
+		// "FOR" stmt "END" stmt
+		node = &tree{
+			Kind:  ndFor,
+			Token: &tokens[*index-1],
+		}
+		node.Left, err = stmt(tokens, index)
+		if err != nil {
+			return nil, err
+		}
+
+		if consume(tokens, index, tkEnd) {
+			// "END"
+			child := &tree{
+				Kind:  ndEnd,
+				Token: &tokens[*index-1],
+			}
+			node.Right = child
+
+			child.Left, err = stmt(tokens, index)
+			if err != nil {
+				return nil, err
+			}
+		} else {
+			return nil, fmt.Errorf("can not parse: expected /* END */, but got %v", tokens[*index].kind)
+		}
+		return node, nil
 	}
 	return node, nil
 }
@@ -189,3 +233,28 @@ func (t *tree) countInner(count *int) {
 		t.Right.countInner(count)
 	}
 }
+
+// compileはIF, ELIFの条件式とFORの定義を事前に解析しておく
+func (t *tree) compile(evaluator ConditionEvaluator) error {
+	if t == nil {
+		return nil
+	}
+	switch t.Kind {
+	case ndIf, ndElif:
+		cond, err := evaluator.Compile(t.Token.condition)
+		if err != nil {
+			return err
+		}
+		t.cond = cond
+	case ndFor:
+		loop, err := parseLoop(t.Token.condition)
+		if err != nil {
+			return err
+		}
+		t.loop = loop
+	}
+	if err := t.Left.compile(evaluator); err != nil {
+		return err
+	}
+	return t.Right.compile(evaluator)
+}
@@ -0,0 +1,422 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"go/format"
+	"regexp"
+	"strconv"
+	"strings"
+	"text/template"
+	"unicode"
+
+	"github.com/future-architect/go-twowaysql"
+)
+
+// queryFile is a 2-way SQL file to generate a function for.
+type queryFile struct {
+	// name is the slash-separated path of the file such as "persons/find.sql".
+	name string
+	src  string
+}
+
+// generateはクエリごとにパラメータの構造体と関数を生成する
+// sがnilでなければ、行を返すクエリの列を解決して結果の構造体も生成する
+func generate(pkg string, files []queryFile, s *schema) ([]byte, error) {
+	data := struct {
+		Package string
+		Queries []*query
+		Imports []string
+	}{Package: pkg}
+
+	seen := map[string]string{}
+	for _, f := range files {
+		q, err := newQuery(f, s)
+		if err != nil {
+			return nil, err
+		}
+		if prev, ok := seen[q.Name]; ok {
+			return nil, fmt.Errorf("%s and %s both generate %s", prev, f.name, q.Name)
+		}
+		seen[q.Name] = f.name
+		data.Queries = append(data.Queries, q)
+	}
+	data.Imports = imports(data.Queries)
+
+	var b bytes.Buffer
+	if err := fileTemplate.Execute(&b, data); err != nil {
+		return nil, err
+	}
+	src, err := format.Source(b.Bytes())
+	if err != nil {
+		return nil, fmt.Errorf("format generated code: %w", err)
+	}
+	return src, nil
+}
+
+// query is the data to generate the code of a file.
+type query struct {
+	File    string
+	Name    string
+	Literal string
+	Select  bool
+	Structs []*structType
+	// Row is the struct of the rows. It is nil if the columns are not resolved.
+	Row *structType
+}
+
+type structType struct {
+	Name   string
+	Fields []structField
+}
+
+type structField struct {
+	Name string
+	Type string
+	Tag  string
+}
+
+func newQuery(f queryFile, s *schema) (*query, error) {
+	tmpl, err := twowaysql.Compile(f.src)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", f.name, err)
+	}
+
+	name := goName(strings.TrimSuffix(f.name, ".sql"))
+	q := &query{
+		File:    f.name,
+		Name:    name,
+		Literal: goString(f.src),
+		Select:  returnsRows(f.src),
+	}
+
+	root := &paramNode{}
+	for _, p := range tmpl.Params() {
+		root.add(p)
+	}
+	q.Structs = root.structs(name + "Params")
+
+	if s != nil {
+		columns, ok, err := resolveColumns(s, f.src)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", f.name, err)
+		}
+		if ok {
+			q.Select = true
+			q.Row, err = rowStruct(name+"Row", columns)
+			if err != nil {
+				return nil, fmt.Errorf("%s: %w", f.name, err)
+			}
+		}
+	}
+	return q, nil
+}
+
+// rowStructは結果の列からdbタグの付いた構造体を作る
+func rowStruct(typeName string, columns []resultColumn) (*structType, error) {
+	s := &structType{Name: typeName}
+	seen := map[string]string{}
+	for _, c := range columns {
+		name := goName(c.name)
+		if prev, ok := seen[name]; ok {
+			return nil, fmt.Errorf("columns %s and %s both generate the field %s", prev, c.name, name)
+		}
+		seen[name] = c.name
+		s.Fields = append(s.Fields, structField{
+			Name: name,
+			Type: c.typ,
+			Tag:  fmt.Sprintf("`db:%q`", c.name),
+		})
+	}
+	return s, nil
+}
+
+// importsは生成するコードで使うパッケージを返す
+func imports(queries []*query) []string {
+	var useSQL, useTime bool
+	check := func(s *structType) {
+		for _, f := range s.Fields {
+			useSQL = useSQL || strings.Contains(f.Type, "sql.")
+			useTime = useTime || strings.Contains(f.Type, "time.")
+		}
+	}
+	for _, q := range queries {
+		useSQL = useSQL || !q.Select
+		for _, s := range q.Structs {
+			check(s)
+		}
+		if q.Row != nil {
+			check(q.Row)
+		}
+	}
+
+	result := []string{"context"}
+	if useSQL {
+		result = append(result, "database/sql")
+	}
+	if useTime {
+		result = append(result, "time")
+	}
+	return result
+}
+
+// paramNodeは一つのパラメータの使われ方をまとめたもの
+// name.fieldの形式で参照されていれば構造体、FORで使われていればスライスにする
+type paramNode struct {
+	name     string
+	kind     twowaysql.ParamKind
+	sample   string
+	children []*paramNode
+	elem     *paramNode /* for FOR */
+}
+
+func (n *paramNode) add(p twowaysql.Param) {
+	target := n
+	if p.Path != "" {
+		for _, name := range strings.Split(p.Path, ".") {
+			target = target.child(name)
+		}
+	}
+
+	if p.Kind == twowaysql.ParamLoop {
+		if target.elem == nil {
+			target.elem = &paramNode{}
+		}
+		for _, elem := range p.Elem {
+			target.elem.add(elem)
+		}
+		return
+	}
+	// 型を推測する手がかりとして強いものを優先する
+	if target.kind == 0 || priority(p.Kind) < priority(target.kind) || p.Kind == target.kind && target.sample == "" {
+		target.kind = p.Kind
+		target.sample = p.Sample
+	}
+}
+
+func (n *paramNode) child(name string) *paramNode {
+	for _, c := range n.children {
+		if c.name == name {
+			return c
+		}
+	}
+	c := &paramNode{name: name}
+	n.children = append(n.children, c)
+	return c
+}
+
+func priority(kind twowaysql.ParamKind) int {
+	switch kind {
+	case twowaysql.ParamBind:
+		return 0
+	case twowaysql.ParamEmbed:
+		return 1
+	}
+	return 2
+}
+
+// structsはnを構造体typeNameとして、中で使う構造体もあわせて返す
+func (n *paramNode) structs(typeName string) []*structType {
+	s := &structType{Name: typeName}
+	result := []*structType{s}
+	for _, c := range n.children {
+		fieldType, nested := c.goType(typeName + goName(c.name))
+		s.Fields = append(s.Fields, structField{
+			Name: goName(c.name),
+			Type: fieldType,
+			Tag:  fmt.Sprintf("`twowaysql:%q`", c.name),
+		})
+		result = append(result, nested...)
+	}
+	return result
+}
+
+// goTypeはパラメータのGoの型と、そのために必要な構造体を返す
+func (n *paramNode) goType(typeName string) (string, []*structType) {
+	switch {
+	case n.elem != nil:
+		elemType, nested := n.elem.goType(typeName + "Elem")
+		return "[]" + elemType, nested
+	case len(n.children) > 0:
+		return typeName, n.structs(typeName)
+	case n.kind == twowaysql.ParamEmbed:
+		return "string", nil
+	case n.kind == twowaysql.ParamCondition && n.sample == "":
+		// 真偽値として評価されているだけ
+		return "bool", nil
+	}
+	return inferType(n.sample), nil
+}
+
+var (
+	intPattern   = regexp.MustCompile(`^[-+]?[0-9]+$`)
+	floatPattern = regexp.MustCompile(`^[-+]?([0-9]+\.[0-9]*|\.[0-9]+)([eE][-+]?[0-9]+)?$|^[-+]?[0-9]+[eE][-+]?[0-9]+$`)
+)
+
+// inferTypeはバインド変数のサンプルの値から型を推測する
+//
+//	1000        -> int
+//	1.5         -> float64
+//	'Tim'       -> string
+//	true        -> bool
+//	(3, 5, 7)   -> []int
+//
+// 推測できない場合はinterface{}にする
+func inferType(sample string) string {
+	sample = strings.TrimSpace(sample)
+	switch {
+	case sample == "":
+		return "interface{}"
+	case sample[0] == '\'' || sample[0] == '"':
+		return "string"
+	case sample[0] == '(':
+		elems := splitList(strings.TrimSuffix(sample[1:], ")"))
+		if len(elems) == 0 {
+			return "[]interface{}"
+		}
+		return "[]" + inferType(elems[0])
+	case intPattern.MatchString(sample):
+		return "int"
+	case floatPattern.MatchString(sample):
+		return "float64"
+	case strings.EqualFold(sample, "true") || strings.EqualFold(sample, "false"):
+		return "bool"
+	}
+	return "interface{}"
+}
+
+// splitListは括弧の中をカンマで区切る。引用符の中のカンマでは区切らない
+func splitList(s string) []string {
+	var elems []string
+	var quote rune
+	start := 0
+	for i, r := range s {
+		switch {
+		case quote != 0:
+			if r == quote {
+				quote = 0
+			}
+		case r == '\'' || r == '"':
+			quote = r
+		case r == ',':
+			elems = append(elems, strings.TrimSpace(s[start:i]))
+			start = i + 1
+		}
+	}
+	if last := strings.TrimSpace(s[start:]); last != "" || len(elems) > 0 {
+		elems = append(elems, last)
+	}
+	return elems
+}
+
+// 行コメントとブロックコメントを読み飛ばして先頭の単語を見る
+var leadingComments = regexp.MustCompile(`^(\s+|--[^\n]*|/\*(?s:.*?)\*/|\()*`)
+var returningPattern = regexp.MustCompile(`(?i)\bRETURNING\b`)
+
+// returnsRowsはクエリが行を返すかを返す。行を返すものはSelect、そうでなければExecを使う
+func returnsRows(src string) bool {
+	rest := leadingComments.ReplaceAllString(src, "")
+	end := strings.IndexFunc(rest, func(r rune) bool { return !unicode.IsLetter(r) })
+	if end < 0 {
+		end = len(rest)
+	}
+	switch strings.ToUpper(rest[:end]) {
+	case "SELECT", "WITH", "VALUES", "TABLE", "SHOW", "EXPLAIN":
+		return true
+	}
+	return returningPattern.MatchString(src)
+}
+
+// 頭字語はすべて大文字にする
+var initialisms = map[string]bool{
+	"API": true, "HTTP": true, "ID": true, "IP": true, "JSON": true,
+	"SQL": true, "URL": true, "UUID": true, "XML": true,
+}
+
+// goNameはパラメータ名やファイル名をエクスポートされたGoの識別子にする
+//
+//	maxEmpNo          -> MaxEmpNo
+//	first_name        -> FirstName
+//	persons/find_all  -> PersonsFindAll
+//	dept_id           -> DeptID
+func goName(s string) string {
+	var b strings.Builder
+	for _, word := range strings.FieldsFunc(s, func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	}) {
+		if upper := strings.ToUpper(word); initialisms[upper] {
+			b.WriteString(upper)
+			continue
+		}
+		runes := []rune(word)
+		runes[0] = unicode.ToUpper(runes[0])
+		b.WriteString(string(runes))
+	}
+	name := b.String()
+	if name == "" || unicode.IsDigit([]rune(name)[0]) {
+		name = "Q" + name
+	}
+	return name
+}
+
+// goStringはクエリをGoの文字列リテラルにする。できるだけ生文字列リテラルを使う
+func goString(s string) string {
+	if !strings.Contains(s, "`") && !strings.Contains(s, "\r") {
+		return "`" + s + "`"
+	}
+	return strconv.Quote(s)
+}
+
+func lowerFirst(s string) string {
+	runes := []rune(s)
+	runes[0] = unicode.ToLower(runes[0])
+	return string(runes)
+}
+
+var fileTemplate = template.Must(template.New("file").Funcs(template.FuncMap{
+	"lowerFirst": lowerFirst,
+}).Parse(`// Code generated by twowaysql-gen. DO NOT EDIT.
+
+package {{.Package}}
+
+import (
+{{- range .Imports}}
+	"{{.}}"
+{{- end}}
+
+	"github.com/future-architect/go-twowaysql"
+)
+{{range $q := .Queries}}
+{{- range $i, $s := .Structs}}
+{{if eq $i 0}}// {{$s.Name}} is the parameters of {{$q.File}}.{{else}}// {{$s.Name}} is a part of the parameters of {{$q.File}}.{{end}}
+type {{$s.Name}} struct {
+{{- range .Fields}}
+	{{.Name}} {{.Type}} {{.Tag}}
+{{- end}}
+}
+{{end}}
+const {{lowerFirst .Name}}Query = {{.Literal}}
+{{if .Row}}
+// {{.Row.Name}} is a row of {{.File}}.
+type {{.Row.Name}} struct {
+{{- range .Row.Fields}}
+	{{.Name}} {{.Type}} {{.Tag}}
+{{- end}}
+}
+
+// {{.Name}} runs {{.File}} and returns the rows.
+func {{.Name}}(ctx context.Context, q twowaysql.Querier, params *{{.Name}}Params) ([]{{.Row.Name}}, error) {
+	return twowaysql.SelectAs[{{.Row.Name}}](ctx, q, {{lowerFirst .Name}}Query, params)
+}
+{{else if .Select}}
+// {{.Name}} runs {{.File}} and scans the rows into dest.
+func {{.Name}}(ctx context.Context, q twowaysql.Querier, dest interface{}, params *{{.Name}}Params) error {
+	return q.Select(ctx, dest, {{lowerFirst .Name}}Query, params)
+}
+{{else}}
+// {{.Name}} runs {{.File}}.
+func {{.Name}}(ctx context.Context, q twowaysql.Querier, params *{{.Name}}Params) (sql.Result, error) {
+	return q.Exec(ctx, {{lowerFirst .Name}}Query, params)
+}
+{{end}}
+{{- end}}`))
@@ -0,0 +1,154 @@
+package main
+
+import (
+	"os"
+	"testing"
+)
+
+func TestGenerate(t *testing.T) {
+	ddl, err := os.ReadFile("../../postgres/init/init.sql")
+	if err != nil {
+		t.Fatal(err)
+	}
+	s, err := parseDDL(string(ddl))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name   string
+		schema *schema
+		golden string
+	}{
+		{name: "without schema", golden: "testdata/queries.go.golden"},
+		{name: "with schema", schema: s, golden: "testdata/queries_schema.go.golden"},
+	}
+
+	files, err := readFiles([]string{"testdata"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := generate("queries", files, tt.schema)
+			if err != nil {
+				t.Fatal(err)
+			}
+			want, err := os.ReadFile(tt.golden)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if string(got) != string(want) {
+				t.Errorf("Doesn't Match\nexpected: \n%s\n but got: \n%s\n", want, got)
+			}
+		})
+	}
+}
+
+func TestGenerateAbnormal(t *testing.T) {
+	tests := []struct {
+		name      string
+		files     []queryFile
+		wantError string
+	}{
+		{
+			name:      "parse error",
+			files:     []queryFile{{name: "broken.sql", src: "SELECT * FROM persons /* IF deptNo */"}},
+			wantError: "broken.sql: can not parse: expected /* END */, but got 7",
+		},
+		{
+			name: "same name",
+			files: []queryFile{
+				{name: "find_persons.sql", src: "SELECT * FROM persons"},
+				{name: "find/persons.sql", src: "SELECT * FROM persons"},
+			},
+			wantError: "find_persons.sql and find/persons.sql both generate FindPersons",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := generate("queries", tt.files, nil)
+			if err == nil {
+				t.Fatal("should return error")
+			}
+			if err.Error() != tt.wantError {
+				t.Errorf("\nexpected:\n%v\nbut got\n%v\n", tt.wantError, err.Error())
+			}
+		})
+	}
+}
+
+func TestInferType(t *testing.T) {
+	tests := []struct {
+		sample string
+		want   string
+	}{
+		{sample: "1000", want: "int"},
+		{sample: "-1", want: "int"},
+		{sample: "1.5", want: "float64"},
+		{sample: "1e3", want: "float64"},
+		{sample: "'Tim'", want: "string"},
+		{sample: `"Tim"`, want: "string"},
+		{sample: "TRUE", want: "bool"},
+		{sample: "(3, 5, 7)", want: "[]int"},
+		{sample: "('M', 'F')", want: "[]string"},
+		{sample: "('a,b')", want: "[]string"},
+		{sample: "()", want: "[]interface{}"},
+		{sample: "null", want: "interface{}"},
+		{sample: "CURRENT_DATE", want: "interface{}"},
+		{sample: "", want: "interface{}"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.sample, func(t *testing.T) {
+			if got := inferType(tt.sample); got != tt.want {
+				t.Errorf("Doesn't Match\nexpected: %s\n but got: %s\n", tt.want, got)
+			}
+		})
+	}
+}
+
+func TestGoName(t *testing.T) {
+	tests := []struct {
+		input string
+		want  string
+	}{
+		{input: "maxEmpNo", want: "MaxEmpNo"},
+		{input: "first_name", want: "FirstName"},
+		{input: "persons/find_all", want: "PersonsFindAll"},
+		{input: "dept_id", want: "DeptID"},
+		{input: "2020/report", want: "Q2020Report"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.input, func(t *testing.T) {
+			if got := goName(tt.input); got != tt.want {
+				t.Errorf("Doesn't Match\nexpected: %s\n but got: %s\n", tt.want, got)
+			}
+		})
+	}
+}
+
+func TestReturnsRows(t *testing.T) {
+	tests := []struct {
+		input string
+		want  bool
+	}{
+		{input: "SELECT * FROM persons", want: true},
+		{input: "-- comment\n/* IF x */ /* END */\nselect 1", want: true},
+		{input: "(SELECT 1) UNION (SELECT 2)", want: true},
+		{input: "WITH t AS (SELECT 1) SELECT * FROM t", want: true},
+		{input: "INSERT INTO persons (first_name) VALUES ('a') RETURNING employee_no", want: true},
+		{input: "UPDATE persons SET first_name = 'a'", want: false},
+		{input: "DELETE FROM persons", want: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.input, func(t *testing.T) {
+			if got := returnsRows(tt.input); got != tt.want {
+				t.Errorf("Doesn't Match\nexpected: %v\n but got: %v\n", tt.want, got)
+			}
+		})
+	}
+}
@@ -0,0 +1,183 @@
+package main
+
+import (
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+type sqlTokenKind int
+
+const (
+	sqlWord sqlTokenKind = iota + 1
+	sqlQuotedIdent
+	sqlString
+	sqlNumber
+	sqlSymbol
+)
+
+type sqlToken struct {
+	kind sqlTokenKind
+	str  string
+}
+
+// lexSQLはSQLを語、引用符で囲まれた識別子、文字列、数値、記号に分ける
+// コメントは読み飛ばすので、2-Way SQLの指示は取り除かれてサンプルの値だけが残る
+func lexSQL(src string) []sqlToken {
+	var tokens []sqlToken
+	for len(src) > 0 {
+		r, size := utf8.DecodeRuneInString(src)
+		n := size
+		kind := sqlSymbol
+		switch {
+		case unicode.IsSpace(r):
+			src = src[size:]
+			continue
+		case strings.HasPrefix(src, "--"):
+			if n = strings.IndexByte(src, '\n'); n < 0 {
+				n = len(src)
+			}
+			src = src[n:]
+			continue
+		case strings.HasPrefix(src, "/*"):
+			if n = strings.Index(src, "*/"); n < 0 {
+				n = len(src)
+			} else {
+				n += 2
+			}
+			src = src[n:]
+			continue
+		case r == '\'':
+			kind, n = sqlString, quotedEnd(src)
+		case r == '"' || r == '`':
+			kind, n = sqlQuotedIdent, quotedEnd(src)
+		case unicode.IsDigit(r):
+			kind = sqlNumber
+			n = len(src) - len(strings.TrimLeftFunc(src, func(r rune) bool {
+				return unicode.IsDigit(r) || r == '.'
+			}))
+		case r == '_' || unicode.IsLetter(r):
+			kind = sqlWord
+			n = len(src) - len(strings.TrimLeftFunc(src, func(r rune) bool {
+				return r == '_' || r == '$' || unicode.IsLetter(r) || unicode.IsDigit(r)
+			}))
+		case strings.HasPrefix(src, "::"):
+			n = 2
+		}
+		tokens = append(tokens, sqlToken{kind: kind, str: src[:n]})
+		src = src[n:]
+	}
+	return tokens
+}
+
+// quotedEndは先頭の引用符に対応する閉じ引用符の直後の位置を返す。引用符を二つ重ねるとエスケープになる
+func quotedEnd(src string) int {
+	quote := src[0]
+	for i := 1; i < len(src); i++ {
+		if src[i] != quote {
+			continue
+		}
+		if i+1 < len(src) && src[i+1] == quote {
+			i++
+			continue
+		}
+		return i + 1
+	}
+	return len(src)
+}
+
+func isWord(tokens []sqlToken, i int, word string) bool {
+	return i >= 0 && i < len(tokens) && tokens[i].kind == sqlWord && strings.EqualFold(tokens[i].str, word)
+}
+
+func isSymbol(tokens []sqlToken, i int, symbol string) bool {
+	return i >= 0 && i < len(tokens) && tokens[i].kind == sqlSymbol && tokens[i].str == symbol
+}
+
+func isIdent(tokens []sqlToken, i int) bool {
+	return i >= 0 && i < len(tokens) && (tokens[i].kind == sqlWord || tokens[i].kind == sqlQuotedIdent)
+}
+
+func tokenStr(tokens []sqlToken, i int) string {
+	if i < 0 || i >= len(tokens) {
+		return "end of query"
+	}
+	return tokens[i].str
+}
+
+// identNameは識別子の名前を返す。引用符で囲まれていなければ小文字にする
+func identName(tok sqlToken) string {
+	if tok.kind == sqlQuotedIdent {
+		return strings.ReplaceAll(tok.str[1:len(tok.str)-1], tok.str[:1]+tok.str[:1], tok.str[:1])
+	}
+	return strings.ToLower(tok.str)
+}
+
+// qualifiedNameはschema.tableの形式の名前と、その次の位置を返す
+func qualifiedName(tokens []sqlToken, i int) (string, int) {
+	if !isIdent(tokens, i) {
+		return "", i
+	}
+	names := []string{identName(tokens[i])}
+	i++
+	for isSymbol(tokens, i, ".") && isIdent(tokens, i+1) {
+		names = append(names, identName(tokens[i+1]))
+		i += 2
+	}
+	return strings.Join(names, "."), i
+}
+
+// closingParenはopenの位置の開き括弧に対応する閉じ括弧の位置を返す
+func closingParen(tokens []sqlToken, open int) int {
+	depth := 0
+	for i := open; i < len(tokens); i++ {
+		switch {
+		case isSymbol(tokens, i, "("):
+			depth++
+		case isSymbol(tokens, i, ")"):
+			depth--
+			if depth == 0 {
+				return i
+			}
+		}
+	}
+	return -1
+}
+
+// splitTopLevelは括弧の外にあるsepで区切る
+func splitTopLevel(tokens []sqlToken, sep string) [][]sqlToken {
+	var parts [][]sqlToken
+	start, depth := 0, 0
+	for i := range tokens {
+		switch {
+		case isSymbol(tokens, i, "("):
+			depth++
+		case isSymbol(tokens, i, ")"):
+			depth--
+		case depth == 0 && isSymbol(tokens, i, sep):
+			parts = append(parts, tokens[start:i])
+			start = i + 1
+		}
+	}
+	return append(parts, tokens[start:])
+}
+
+// findTopLevelはfrom以降で括弧の外にある語wordsのいずれかの位置を返す
+func findTopLevel(tokens []sqlToken, from int, words ...string) int {
+	depth := 0
+	for i := from; i < len(tokens); i++ {
+		switch {
+		case isSymbol(tokens, i, "("):
+			depth++
+		case isSymbol(tokens, i, ")"):
+			depth--
+		case depth == 0:
+			for _, w := range words {
+				if isWord(tokens, i, w) || isSymbol(tokens, i, w) {
+					return i
+				}
+			}
+		}
+	}
+	return -1
+}
@@ -0,0 +1,147 @@
+// Command twowaysql-gen generates typed Go functions from 2-way SQL files.
+//
+// For each file, it generates a struct of the parameters with twowaysql tags
+// and a function calling Select, or Exec for statements that return no rows.
+// The parameters are discovered from the binds, the IF conditions and the FOR sources,
+// and their types are inferred from the sample values:
+//
+//	/*maxEmpNo*/1000      ->  MaxEmpNo int     `twowaysql:"maxEmpNo"`
+//	/*name*/'Tim'         ->  Name     string  `twowaysql:"name"`
+//	/*deptNos*/(3, 5, 7)  ->  DeptNos  []int   `twowaysql:"deptNos"`
+//
+// Usage:
+//
+//	twowaysql-gen [-pkg name] [-o file] path...
+//
+// A path is a .sql file or a directory searched recursively for .sql files.
+// The function of sql/persons/find.sql given as the directory sql is named PersonsFind.
+//
+// With -schema, a DDL file such as postgres/init/init.sql is parsed offline,
+// or with -db, the schema is read from a PostgreSQL database.
+// The select list of each query, or the RETURNING list, is then resolved against the schema
+// and a struct of the rows with db tags is generated. The function returns the rows:
+//
+//	func PersonsFind(ctx context.Context, q twowaysql.Querier, params *PersonsFindParams) ([]PersonsFindRow, error)
+//
+// Nullable columns are generated as sql.NullString and the like.
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+func main() {
+	pkg := flag.String("pkg", "queries", "package name of the generated file")
+	out := flag.String("o", "", "output file (default stdout)")
+	ddl := flag.String("schema", "", "DDL file with CREATE TABLE statements to generate the row structs from")
+	dbURL := flag.String("db", "", "PostgreSQL URL to read the schema from instead of -schema")
+	flag.Usage = func() {
+		fmt.Fprintf(flag.CommandLine.Output(), "usage: twowaysql-gen [-pkg name] [-o file] [-schema file | -db url] path...\n")
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+	if flag.NArg() == 0 {
+		flag.Usage()
+		os.Exit(2)
+	}
+
+	if *ddl != "" && *dbURL != "" {
+		fmt.Fprintln(os.Stderr, "twowaysql-gen: -schema and -db can not be used together")
+		os.Exit(2)
+	}
+
+	if err := run(*pkg, *out, *ddl, *dbURL, flag.Args()); err != nil {
+		fmt.Fprintf(os.Stderr, "twowaysql-gen: %v\n", err)
+		os.Exit(1)
+	}
+}
+
+func run(pkg, out, ddl, dbURL string, paths []string) error {
+	files, err := readFiles(paths)
+	if err != nil {
+		return err
+	}
+	if len(files) == 0 {
+		return errors.New("no .sql files found")
+	}
+	s, err := loadSchema(ddl, dbURL)
+	if err != nil {
+		return err
+	}
+	src, err := generate(pkg, files, s)
+	if err != nil {
+		return err
+	}
+	if out == "" {
+		_, err = os.Stdout.Write(src)
+		return err
+	}
+	return os.WriteFile(out, src, 0o644)
+}
+
+// loadSchemaは-schemaまたは-dbで指定されたスキーマを読み込む。どちらもなければnilを返す
+func loadSchema(ddl, dbURL string) (*schema, error) {
+	switch {
+	case ddl != "":
+		src, err := os.ReadFile(ddl)
+		if err != nil {
+			return nil, err
+		}
+		s, err := parseDDL(string(src))
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", ddl, err)
+		}
+		return s, nil
+	case dbURL != "":
+		s, err := loadDBSchema(context.Background(), dbURL)
+		if err != nil {
+			return nil, fmt.Errorf("read schema from the database: %w", err)
+		}
+		return s, nil
+	}
+	return nil, nil
+}
+
+// readFilesは引数のファイルと、ディレクトリ以下の.sqlファイルを読み込む
+// ディレクトリ以下のファイルはディレクトリからの相対パスを名前にする
+func readFiles(paths []string) ([]queryFile, error) {
+	var files []queryFile
+	for _, path := range paths {
+		info, err := os.Stat(path)
+		if err != nil {
+			return nil, err
+		}
+		if !info.IsDir() {
+			src, err := os.ReadFile(path)
+			if err != nil {
+				return nil, err
+			}
+			files = append(files, queryFile{name: filepath.Base(path), src: string(src)})
+			continue
+		}
+
+		// WalkDirは辞書順に辿るので出力の順序は変わらない
+		err = fs.WalkDir(os.DirFS(path), ".", func(name string, d fs.DirEntry, err error) error {
+			if err != nil || d.IsDir() || !strings.HasSuffix(name, ".sql") {
+				return err
+			}
+			src, err := fs.ReadFile(os.DirFS(path), name)
+			if err != nil {
+				return err
+			}
+			files = append(files, queryFile{name: name, src: string(src)})
+			return nil
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+	return files, nil
+}
@@ -0,0 +1,429 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+)
+
+// resultColumn is a column of the rows returned by a query.
+type resultColumn struct {
+	name string
+	typ  string /* Go type */
+}
+
+// tableRefはFROMに書かれたテーブルと別名を表す
+type tableRef struct {
+	alias    string
+	table    *table
+	nullable bool /* LEFT JOINなどで行がない場合がある */
+}
+
+// FROMの後ろに続く句
+var clauseKeywords = []string{
+	"WHERE", "GROUP", "HAVING", "ORDER", "LIMIT", "OFFSET", "FETCH", "FOR", "WINDOW",
+	"UNION", "INTERSECT", "EXCEPT", "RETURNING", ";",
+}
+
+// resolveColumnsはクエリが返す列をスキーマから解決する
+// SELECT文とRETURNINGのあるINSERT, UPDATE, DELETE文に対応する。それ以外はokがfalseになる
+// WITHで定義した問い合わせの列は解決しないので、FROMで参照している場合もokがfalseになる
+func resolveColumns(s *schema, src string) ([]resultColumn, bool, error) {
+	tokens := lexSQL(src)
+	first := 0
+	for isSymbol(tokens, first, "(") {
+		first++
+	}
+
+	var items []sqlToken
+	var refs []tableRef
+	var ok bool
+	var err error
+	switch strings.ToUpper(tokenStr(tokens, first)) {
+	case "SELECT", "WITH":
+		sel := findTopLevel(tokens, first, "SELECT")
+		if sel < 0 {
+			return nil, false, nil
+		}
+		end := findTopLevel(tokens, sel+1, append([]string{"FROM"}, clauseKeywords...)...)
+		if end < 0 {
+			end = len(tokens)
+		}
+		items = tokens[sel+1 : end]
+		if isWord(tokens, end, "FROM") {
+			fromEnd := findTopLevel(tokens, end+1, clauseKeywords...)
+			if fromEnd < 0 {
+				fromEnd = len(tokens)
+			}
+			refs, ok, err = fromTables(s, withNames(tokens, first), tokens[end+1:fromEnd])
+			if !ok || err != nil {
+				return nil, false, err
+			}
+		}
+	case "INSERT", "UPDATE", "DELETE":
+		ret := findTopLevel(tokens, first, "RETURNING")
+		if ret < 0 {
+			return nil, false, nil
+		}
+		end := findTopLevel(tokens, ret+1, ";")
+		if end < 0 {
+			end = len(tokens)
+		}
+		items = tokens[ret+1 : end]
+		if refs, err = targetTable(s, tokens, first); err != nil {
+			return nil, false, err
+		}
+	default:
+		return nil, false, nil
+	}
+
+	columns, err := selectColumns(items, refs)
+	if err != nil {
+		return nil, false, err
+	}
+	return columns, true, nil
+}
+
+// withNamesはWITH句で定義された問い合わせの名前を返す
+func withNames(tokens []sqlToken, first int) map[string]bool {
+	names := map[string]bool{}
+	if !isWord(tokens, first, "WITH") {
+		return names
+	}
+	i := first + 1
+	if isWord(tokens, i, "RECURSIVE") {
+		i++
+	}
+	for isIdent(tokens, i) {
+		names[identName(tokens[i])] = true
+		i++
+		// name (col, ...) AS [NOT] MATERIALIZED ( ... ), ...
+		if isSymbol(tokens, i, "(") {
+			if i = closingParen(tokens, i); i < 0 {
+				break
+			}
+			i++
+		}
+		for isWord(tokens, i, "AS") || isWord(tokens, i, "NOT") || isWord(tokens, i, "MATERIALIZED") {
+			i++
+		}
+		if !isSymbol(tokens, i, "(") {
+			break
+		}
+		if i = closingParen(tokens, i); i < 0 || !isSymbol(tokens, i+1, ",") {
+			break
+		}
+		i += 2
+	}
+	return names
+}
+
+// fromTablesはFROM句のテーブルを読み取る
+// WITHで定義した問い合わせを参照している場合はokがfalseになる
+func fromTables(s *schema, with map[string]bool, tokens []sqlToken) ([]tableRef, bool, error) {
+	var refs []tableRef
+	expectTable := true
+	var join string
+	for i := 0; i < len(tokens); i++ {
+		switch {
+		case isSymbol(tokens, i, "("):
+			if expectTable {
+				return nil, false, fmt.Errorf("subqueries in FROM are not supported")
+			}
+			i = closingParen(tokens, i)
+			if i < 0 {
+				return nil, false, fmt.Errorf("unclosed parenthesis in FROM")
+			}
+		case isSymbol(tokens, i, ","):
+			expectTable, join = true, ""
+		case isWord(tokens, i, "JOIN"):
+			expectTable = true
+		case isWord(tokens, i, "LEFT") || isWord(tokens, i, "RIGHT") || isWord(tokens, i, "FULL"):
+			join = strings.ToUpper(tokens[i].str)
+		case isWord(tokens, i, "LATERAL") || isWord(tokens, i, "ONLY"):
+		case expectTable && isIdent(tokens, i):
+			name, next := qualifiedName(tokens, i)
+			if with[name] {
+				return nil, false, nil
+			}
+			t, ok := s.table(name)
+			if !ok {
+				return nil, false, fmt.Errorf("table %s is not defined in the schema", name)
+			}
+			ref := tableRef{alias: unqualified(name), table: t}
+			if isWord(tokens, next, "AS") {
+				next++
+			}
+			if isIdent(tokens, next) && !isJoinKeyword(tokens[next]) && findTopLevel(tokens[next:next+1], 0, clauseKeywords...) < 0 {
+				ref.alias = identName(tokens[next])
+				next++
+			}
+			switch join {
+			case "LEFT":
+				ref.nullable = true
+			case "RIGHT", "FULL":
+				for k := range refs {
+					refs[k].nullable = true
+				}
+				ref.nullable = join == "FULL"
+			}
+			refs = append(refs, ref)
+			expectTable, join = false, ""
+			i = next - 1
+		}
+	}
+	return refs, true, nil
+}
+
+func isJoinKeyword(tok sqlToken) bool {
+	if tok.kind != sqlWord {
+		return false
+	}
+	switch strings.ToUpper(tok.str) {
+	case "ON", "USING", "JOIN", "LEFT", "RIGHT", "FULL", "INNER", "CROSS", "NATURAL", "OUTER":
+		return true
+	}
+	return false
+}
+
+// targetTableはINSERT INTO, UPDATE, DELETE FROMの対象のテーブルを読み取る
+func targetTable(s *schema, tokens []sqlToken, first int) ([]tableRef, error) {
+	i := first + 1
+	if isWord(tokens, i, "INTO") || isWord(tokens, i, "FROM") {
+		i++
+	}
+	if isWord(tokens, i, "ONLY") {
+		i++
+	}
+	name, next := qualifiedName(tokens, i)
+	t, ok := s.table(name)
+	if !ok {
+		return nil, fmt.Errorf("table %s is not defined in the schema", name)
+	}
+	ref := tableRef{alias: unqualified(name), table: t}
+	if isWord(tokens, next, "AS") {
+		next++
+	}
+	if isIdent(tokens, next) && !isWord(tokens, next, "SET") && !isWord(tokens, next, "VALUES") &&
+		!isWord(tokens, next, "WHERE") && !isWord(tokens, next, "USING") && !isWord(tokens, next, "DEFAULT") {
+		ref.alias = identName(tokens[next])
+	}
+	return []tableRef{ref}, nil
+}
+
+// selectColumnsは選択リストの各項目の名前と型を解決する
+func selectColumns(tokens []sqlToken, refs []tableRef) ([]resultColumn, error) {
+	if isWord(tokens, 0, "DISTINCT") {
+		tokens = tokens[1:]
+		if isWord(tokens, 0, "ON") && isSymbol(tokens, 1, "(") {
+			tokens = tokens[closingParen(tokens, 1)+1:]
+		}
+	} else if isWord(tokens, 0, "ALL") {
+		tokens = tokens[1:]
+	}
+
+	var columns []resultColumn
+	seen := map[string]bool{}
+	for _, item := range splitTopLevel(tokens, ",") {
+		resolved, err := selectItem(item, refs)
+		if err != nil {
+			return nil, err
+		}
+		for _, c := range resolved {
+			if seen[c.name] {
+				return nil, fmt.Errorf("duplicate column %s in the select list, add an alias", c.name)
+			}
+			seen[c.name] = true
+			columns = append(columns, c)
+		}
+	}
+	return columns, nil
+}
+
+func selectItem(item []sqlToken, refs []tableRef) ([]resultColumn, error) {
+	text := joinTokens(item)
+	if len(item) == 0 {
+		return nil, fmt.Errorf("empty item in the select list")
+	}
+
+	// * または t.*
+	if len(item) == 1 && isSymbol(item, 0, "*") {
+		var columns []resultColumn
+		for _, ref := range refs {
+			columns = append(columns, tableColumns(ref)...)
+		}
+		return columns, nil
+	}
+	if len(item) == 3 && isIdent(item, 0) && isSymbol(item, 1, ".") && isSymbol(item, 2, "*") {
+		ref, err := findRef(refs, identName(item[0]))
+		if err != nil {
+			return nil, err
+		}
+		return tableColumns(ref), nil
+	}
+
+	// 別名
+	var alias string
+	n := len(item)
+	switch {
+	case n >= 3 && isWord(item, n-2, "AS") && isIdent(item, n-1):
+		alias, item = identName(item[n-1]), item[:n-2]
+	case n >= 2 && isIdent(item, n-1) && (isIdent(item, n-2) || isSymbol(item, n-2, ")") ||
+		item[n-2].kind == sqlNumber || item[n-2].kind == sqlString):
+		alias, item = identName(item[n-1]), item[:n-1]
+	}
+
+	name, typ, err := exprType(item, refs)
+	if err != nil {
+		return nil, err
+	}
+	if alias != "" {
+		name = alias
+	}
+	if name == "" {
+		return nil, fmt.Errorf("select item %q has no name, add an alias", text)
+	}
+	return []resultColumn{{name: name, typ: typ}}, nil
+}
+
+// exprTypeは式から列の名前と型を求める。名前が決まらない場合は空になる
+//
+//	t.col, col          -> 列の名前と型
+//	expr::type          -> exprの名前とtypeの型
+//	CAST(expr AS type)  -> exprの名前とtypeの型
+//	count(...)          -> count, int64
+//	f(...)              -> f, interface{}
+func exprType(expr []sqlToken, refs []tableRef) (string, string, error) {
+	// expr::type
+	if cast := findTopLevel(expr, 0, "::"); cast > 0 {
+		name, typ, err := exprType(expr[:cast], refs)
+		if err != nil {
+			return "", "", err
+		}
+		notNull := !strings.HasPrefix(typ, "sql.Null") && typ != "interface{}"
+		return name, goColumnType(joinTypeTokens(expr[cast+1:]), notNull), nil
+	}
+
+	switch {
+	case len(expr) == 1 && isIdent(expr, 0):
+		return columnType(refs, "", identName(expr[0]))
+	case len(expr) >= 3 && isIdent(expr, len(expr)-1) && isSymbol(expr, len(expr)-2, "."):
+		qualifier, next := qualifiedName(expr, 0)
+		if next == len(expr) {
+			// schema.table.colはスキーマ名を除いて探す
+			names := strings.Split(qualifier, ".")
+			return columnType(refs, names[len(names)-2], names[len(names)-1])
+		}
+	case len(expr) >= 3 && isIdent(expr, 0) && isSymbol(expr, 1, "(") && closingParen(expr, 1) == len(expr)-1:
+		fn := identName(expr[0])
+		args := expr[2 : len(expr)-1]
+		switch fn {
+		case "count":
+			return fn, "int64", nil
+		case "cast":
+			if as := findTopLevel(args, 0, "AS"); as > 0 {
+				name, typ, err := exprType(args[:as], refs)
+				if err != nil {
+					return "", "", err
+				}
+				notNull := !strings.HasPrefix(typ, "sql.Null") && typ != "interface{}"
+				return name, goColumnType(joinTypeTokens(args[as+1:]), notNull), nil
+			}
+		case "coalesce":
+			if parts := splitTopLevel(args, ","); len(parts) > 0 {
+				_, typ, err := exprType(parts[0], refs)
+				if err != nil {
+					return "", "", err
+				}
+				return fn, notNullType(typ), nil
+			}
+		}
+		return fn, "interface{}", nil
+	}
+	return "", "interface{}", nil
+}
+
+// joinTypeTokensはvarchar(100)の長さを除いて型名をつなげる
+func joinTypeTokens(tokens []sqlToken) string {
+	var words []string
+	for i := 0; i < len(tokens); i++ {
+		if isSymbol(tokens, i, "(") {
+			if i = closingParen(tokens, i); i < 0 {
+				break
+			}
+			continue
+		}
+		words = append(words, tokens[i].str)
+	}
+	return strings.Join(words, " ")
+}
+
+func notNullType(typ string) string {
+	switch typ {
+	case "sql.NullInt16":
+		return "int16"
+	case "sql.NullInt32":
+		return "int32"
+	case "sql.NullInt64":
+		return "int64"
+	case "sql.NullFloat64":
+		return "float64"
+	case "sql.NullString":
+		return "string"
+	case "sql.NullBool":
+		return "bool"
+	case "sql.NullTime":
+		return "time.Time"
+	}
+	return typ
+}
+
+// columnTypeはテーブルの別名と列名から列を探す。別名が空の場合はすべてのテーブルから探す
+func columnType(refs []tableRef, qualifier, name string) (string, string, error) {
+	var found *column
+	var foundRef tableRef
+	for _, ref := range refs {
+		if qualifier != "" && ref.alias != qualifier && ref.table.name != qualifier {
+			continue
+		}
+		c, ok := ref.table.column(name)
+		if !ok {
+			continue
+		}
+		if found != nil {
+			return "", "", fmt.Errorf("column reference %s is ambiguous", name)
+		}
+		found, foundRef = c, ref
+	}
+	if found == nil {
+		if qualifier != "" {
+			return "", "", fmt.Errorf("column %s.%s is not defined in the schema", qualifier, name)
+		}
+		return "", "", fmt.Errorf("column %s is not defined in the schema", name)
+	}
+	return found.name, goColumnType(found.typ, found.notNull && !foundRef.nullable), nil
+}
+
+func findRef(refs []tableRef, alias string) (tableRef, error) {
+	for _, ref := range refs {
+		if ref.alias == alias || ref.table.name == alias {
+			return ref, nil
+		}
+	}
+	return tableRef{}, fmt.Errorf("missing FROM entry for %s", alias)
+}
+
+func tableColumns(ref tableRef) []resultColumn {
+	columns := make([]resultColumn, 0, len(ref.table.columns))
+	for _, c := range ref.table.columns {
+		columns = append(columns, resultColumn{name: c.name, typ: goColumnType(c.typ, c.notNull && !ref.nullable)})
+	}
+	return columns
+}
+
+func joinTokens(tokens []sqlToken) string {
+	strs := make([]string, len(tokens))
+	for i, tok := range tokens {
+		strs[i] = tok.str
+	}
+	return strings.Join(strs, " ")
+}
@@ -0,0 +1,239 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"regexp"
+	"strings"
+
+	_ "github.com/lib/pq"
+)
+
+// schema is the tables the select lists are resolved against.
+type schema struct {
+	tables map[string]*table
+}
+
+type table struct {
+	name    string
+	columns []*column
+}
+
+type column struct {
+	name    string
+	typ     string /* SQL type in lower case such as "integer" or "character varying" */
+	notNull bool
+}
+
+func (s *schema) add(t *table) {
+	if s.tables == nil {
+		s.tables = map[string]*table{}
+	}
+	s.tables[unqualified(t.name)] = t
+}
+
+// tableはテーブルを探す。スキーマ名は区別しない
+func (s *schema) table(name string) (*table, bool) {
+	t, ok := s.tables[unqualified(name)]
+	return t, ok
+}
+
+// unqualifiedはschema.tableからスキーマ名を除く
+func unqualified(name string) string {
+	return name[strings.LastIndexByte(name, '.')+1:]
+}
+
+func (t *table) column(name string) (*column, bool) {
+	for _, c := range t.columns {
+		if c.name == name {
+			return c, true
+		}
+	}
+	return nil, false
+}
+
+// parseDDLはCREATE TABLE文からテーブル定義を読み取る。それ以外の文は読み飛ばす
+func parseDDL(src string) (*schema, error) {
+	s := &schema{}
+	tokens := lexSQL(src)
+	for i := 0; i < len(tokens); i++ {
+		if !isWord(tokens, i, "CREATE") {
+			continue
+		}
+		j := i + 1
+		// CREATE TEMPORARY TABLE, CREATE UNLOGGED TABLEなど
+		for j < len(tokens) && tokens[j].kind == sqlWord && !isWord(tokens, j, "TABLE") && !isWord(tokens, j, "AS") {
+			if isWord(tokens, j, "INDEX") || isWord(tokens, j, "VIEW") || isWord(tokens, j, "FUNCTION") {
+				break
+			}
+			j++
+		}
+		if !isWord(tokens, j, "TABLE") {
+			continue
+		}
+		j++
+		if isWord(tokens, j, "IF") && isWord(tokens, j+1, "NOT") && isWord(tokens, j+2, "EXISTS") {
+			j += 3
+		}
+		name, next := qualifiedName(tokens, j)
+		if name == "" {
+			return nil, fmt.Errorf("can not parse CREATE TABLE: expected table name, but got %q", tokenStr(tokens, j))
+		}
+		if !isSymbol(tokens, next, "(") {
+			// CREATE TABLE ... AS SELECTなどは扱わない
+			continue
+		}
+		t, end, err := parseTableBody(name, tokens, next)
+		if err != nil {
+			return nil, err
+		}
+		s.add(t)
+		i = end
+	}
+	return s, nil
+}
+
+// 列定義の型の後ろに続く制約
+var columnConstraints = map[string]bool{
+	"NOT": true, "NULL": true, "PRIMARY": true, "UNIQUE": true, "DEFAULT": true, "REFERENCES": true,
+	"CHECK": true, "CONSTRAINT": true, "GENERATED": true, "COLLATE": true, "AUTO_INCREMENT": true,
+	"COMMENT": true, "IDENTITY": true,
+}
+
+// parseTableBodyは( ... )の中の列定義を読み取り、閉じ括弧の位置を返す
+func parseTableBody(name string, tokens []sqlToken, open int) (*table, int, error) {
+	t := &table{name: unqualified(name)}
+	end := closingParen(tokens, open)
+	if end < 0 {
+		return nil, 0, fmt.Errorf("can not parse CREATE TABLE %s: unclosed parenthesis", name)
+	}
+
+	for _, def := range splitTopLevel(tokens[open+1:end], ",") {
+		if len(def) == 0 {
+			continue
+		}
+		switch strings.ToUpper(def[0].str) {
+		case "CONSTRAINT", "PRIMARY", "UNIQUE", "FOREIGN", "CHECK", "EXCLUDE", "KEY", "INDEX":
+			if def[0].kind == sqlWord {
+				// 表制約のPRIMARY KEYに含まれる列はNOT NULLになる
+				markPrimaryKey(t, def)
+				continue
+			}
+		case "LIKE":
+			continue
+		}
+
+		c := &column{name: identName(def[0])}
+		var typ []string
+		k := 1
+		for ; k < len(def) && !(def[k].kind == sqlWord && columnConstraints[strings.ToUpper(def[k].str)]); k++ {
+			if def[k].kind == sqlSymbol && def[k].str == "(" {
+				// varchar(100)などの長さは型に含めない
+				k = closingParen(def, k)
+				if k < 0 {
+					return nil, 0, fmt.Errorf("can not parse CREATE TABLE %s: unclosed parenthesis", name)
+				}
+				continue
+			}
+			typ = append(typ, strings.ToLower(def[k].str))
+		}
+		c.typ = strings.Join(typ, " ")
+		for ; k < len(def); k++ {
+			if isWord(def, k, "NOT") && isWord(def, k+1, "NULL") || isWord(def, k, "PRIMARY") && isWord(def, k+1, "KEY") {
+				c.notNull = true
+			}
+		}
+		t.columns = append(t.columns, c)
+	}
+	return t, end, nil
+}
+
+func markPrimaryKey(t *table, def []sqlToken) {
+	for k := range def {
+		if !isWord(def, k, "PRIMARY") || !isWord(def, k+1, "KEY") || !isSymbol(def, k+2, "(") {
+			continue
+		}
+		for _, tok := range def[k+3 : max(closingParen(def, k+2), k+3)] {
+			if tok.kind == sqlWord || tok.kind == sqlQuotedIdent {
+				if c, ok := t.column(identName(tok)); ok {
+					c.notNull = true
+				}
+			}
+		}
+	}
+}
+
+// loadDBSchemaはデータベースのinformation_schemaから現在のスキーマのテーブル定義を読み取る
+// 今のところPostgreSQLのみ対応している
+func loadDBSchema(ctx context.Context, url string) (*schema, error) {
+	db, err := sql.Open("postgres", url)
+	if err != nil {
+		return nil, err
+	}
+	defer db.Close()
+
+	rows, err := db.QueryContext(ctx, `SELECT table_name, column_name, data_type, is_nullable FROM information_schema.columns WHERE table_schema = current_schema() ORDER BY table_name, ordinal_position`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	s := &schema{}
+	for rows.Next() {
+		var tableName, nullable string
+		c := &column{}
+		if err := rows.Scan(&tableName, &c.name, &c.typ, &nullable); err != nil {
+			return nil, err
+		}
+		c.notNull = nullable == "NO"
+		t, ok := s.table(tableName)
+		if !ok {
+			t = &table{name: tableName}
+			s.add(t)
+		}
+		t.columns = append(t.columns, c)
+	}
+	return s, rows.Err()
+}
+
+var arrayType = regexp.MustCompile(`\[\s*\d*\s*\]$|^_|\barray$`)
+
+// goColumnTypeはSQLの型をGoの型にする。NULLを許す列はsql.Null*にする
+// 対応していない型はinterface{}にする
+func goColumnType(typ string, notNull bool) string {
+	typ = strings.TrimSpace(strings.ToLower(typ))
+	if arrayType.MatchString(typ) {
+		return "interface{}"
+	}
+	var base, nullable string
+	switch strings.Fields(typ + " ?")[0] {
+	case "smallint", "int2", "smallserial", "serial2", "tinyint":
+		base, nullable = "int16", "sql.NullInt16"
+	case "integer", "int", "int4", "serial", "serial4", "mediumint":
+		base, nullable = "int32", "sql.NullInt32"
+	case "bigint", "int8", "bigserial", "serial8":
+		base, nullable = "int64", "sql.NullInt64"
+	case "real", "float4", "float8", "float", "double":
+		base, nullable = "float64", "sql.NullFloat64"
+	case "numeric", "decimal", "money":
+		// 精度を落とさないように文字列で受け取る
+		base, nullable = "string", "sql.NullString"
+	case "boolean", "bool":
+		base, nullable = "bool", "sql.NullBool"
+	case "character", "char", "varchar", "nvarchar", "nchar", "text", "citext", "uuid", "name",
+		"tinytext", "mediumtext", "longtext", "enum", "inet", "cidr", "macaddr", "interval":
+		base, nullable = "string", "sql.NullString"
+	case "timestamp", "timestamptz", "date", "datetime", "time", "timetz":
+		base, nullable = "time.Time", "sql.NullTime"
+	case "bytea", "blob", "binary", "varbinary", "json", "jsonb", "longblob", "mediumblob", "tinyblob":
+		// []byteはNULLをnilで表せる
+		return "[]byte"
+	default:
+		return "interface{}"
+	}
+	if notNull {
+		return base
+	}
+	return nullable
+}
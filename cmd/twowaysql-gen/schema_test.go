@@ -0,0 +1,266 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+)
+
+const testDDL = `
+-- 部署
+CREATE TABLE IF NOT EXISTS public.depts (
+	dept_no INTEGER NOT NULL,
+	name VARCHAR(100) NOT NULL DEFAULT 'none',
+	budget NUMERIC(10, 2),
+	created_at TIMESTAMP WITH TIME ZONE NOT NULL,
+	CONSTRAINT depts_pk PRIMARY KEY (dept_no)
+);
+
+CREATE INDEX depts_name ON depts (name);
+
+CREATE TABLE persons (
+	employee_no BIGSERIAL PRIMARY KEY,
+	dept_no INT REFERENCES depts (dept_no),
+	"First Name" TEXT,
+	active BOOLEAN NOT NULL,
+	photo BYTEA,
+	tags TEXT[]
+);
+
+INSERT INTO persons (employee_no) VALUES (1);
+`
+
+func TestParseDDL(t *testing.T) {
+	s, err := parseDDL(testDDL)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		table string
+		want  []column
+	}{
+		{
+			table: "depts",
+			want: []column{
+				{name: "dept_no", typ: "integer", notNull: true},
+				{name: "name", typ: "varchar", notNull: true},
+				{name: "budget", typ: "numeric"},
+				{name: "created_at", typ: "timestamp with time zone", notNull: true},
+			},
+		},
+		{
+			table: "persons",
+			want: []column{
+				{name: "employee_no", typ: "bigserial", notNull: true},
+				{name: "dept_no", typ: "int"},
+				{name: "First Name", typ: "text"},
+				{name: "active", typ: "boolean", notNull: true},
+				{name: "photo", typ: "bytea"},
+				{name: "tags", typ: "text [ ]"},
+			},
+		},
+	}
+
+	if len(s.tables) != len(tests) {
+		t.Errorf("Doesn't Match\nexpected: %d tables\n but got: %v\n", len(tests), s.tables)
+	}
+	for _, tt := range tests {
+		t.Run(tt.table, func(t *testing.T) {
+			tbl, ok := s.table(tt.table)
+			if !ok {
+				t.Fatalf("table %s is not found", tt.table)
+			}
+			var got []column
+			for _, c := range tbl.columns {
+				got = append(got, *c)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Doesn't Match\nexpected: \n%+v\n but got: \n%+v\n", tt.want, got)
+			}
+		})
+	}
+}
+
+func TestGoColumnType(t *testing.T) {
+	tests := []struct {
+		typ     string
+		notNull bool
+		want    string
+	}{
+		{typ: "integer", notNull: true, want: "int32"},
+		{typ: "integer", want: "sql.NullInt32"},
+		{typ: "bigserial", notNull: true, want: "int64"},
+		{typ: "character varying", want: "sql.NullString"},
+		{typ: "double precision", notNull: true, want: "float64"},
+		{typ: "numeric", notNull: true, want: "string"},
+		{typ: "timestamp with time zone", notNull: true, want: "time.Time"},
+		{typ: "date", want: "sql.NullTime"},
+		{typ: "boolean", want: "sql.NullBool"},
+		{typ: "jsonb", want: "[]byte"},
+		{typ: "text [ ]", want: "interface{}"},
+		{typ: "ARRAY", want: "interface{}"},
+		{typ: "USER-DEFINED", want: "interface{}"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.typ, func(t *testing.T) {
+			if got := goColumnType(tt.typ, tt.notNull); got != tt.want {
+				t.Errorf("Doesn't Match\nexpected: %s\n but got: %s\n", tt.want, got)
+			}
+		})
+	}
+}
+
+func TestResolveColumns(t *testing.T) {
+	s, err := parseDDL(testDDL)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name  string
+		input string
+		want  []resultColumn
+	}{
+		{
+			name:  "columns",
+			input: `SELECT employee_no, p.active, persons.photo FROM persons p WHERE employee_no = /*empNo*/1`,
+			want: []resultColumn{
+				{name: "employee_no", typ: "int64"},
+				{name: "active", typ: "bool"},
+				{name: "photo", typ: "[]byte"},
+			},
+		},
+		{
+			name:  "star",
+			input: `SELECT * FROM public.depts ORDER BY /*$sortColumn*/name`,
+			want: []resultColumn{
+				{name: "dept_no", typ: "int32"},
+				{name: "name", typ: "string"},
+				{name: "budget", typ: "sql.NullString"},
+				{name: "created_at", typ: "time.Time"},
+			},
+		},
+		{
+			name:  "left join",
+			input: `SELECT p.employee_no, d.name AS dept_name, p."First Name" FROM persons AS p LEFT JOIN depts d ON p.dept_no = d.dept_no`,
+			want: []resultColumn{
+				{name: "employee_no", typ: "int64"},
+				{name: "dept_name", typ: "sql.NullString"},
+				{name: "First Name", typ: "sql.NullString"},
+			},
+		},
+		{
+			name:  "expressions",
+			input: `SELECT DISTINCT count(*), dept_no::bigint, CAST(budget AS double precision) amount, coalesce(budget, 0) total, max(created_at) latest FROM depts GROUP BY dept_no`,
+			want: []resultColumn{
+				{name: "count", typ: "int64"},
+				{name: "dept_no", typ: "int64"},
+				{name: "amount", typ: "sql.NullFloat64"},
+				{name: "total", typ: "string"},
+				{name: "latest", typ: "interface{}"},
+			},
+		},
+		{
+			name:  "table star",
+			input: `WITH t AS (SELECT 1) SELECT d.*, p.active FROM depts d, persons p`,
+			want: []resultColumn{
+				{name: "dept_no", typ: "int32"},
+				{name: "name", typ: "string"},
+				{name: "budget", typ: "sql.NullString"},
+				{name: "created_at", typ: "time.Time"},
+				{name: "active", typ: "bool"},
+			},
+		},
+		{
+			name:  "returning",
+			input: `UPDATE persons p SET active = /*active*/true WHERE employee_no = /*empNo*/1 RETURNING p.employee_no, active`,
+			want: []resultColumn{
+				{name: "employee_no", typ: "int64"},
+				{name: "active", typ: "bool"},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, ok, err := resolveColumns(s, tt.input)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !ok {
+				t.Fatal("should resolve the columns")
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Doesn't Match\nexpected: \n%+v\n but got: \n%+v\n", tt.want, got)
+			}
+		})
+	}
+}
+
+func TestResolveColumnsAbnormal(t *testing.T) {
+	s, err := parseDDL(testDDL)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name      string
+		input     string
+		wantError string
+	}{
+		{
+			name:      "unknown table",
+			input:     `SELECT * FROM employees`,
+			wantError: "table employees is not defined in the schema",
+		},
+		{
+			name:      "unknown column",
+			input:     `SELECT email FROM persons`,
+			wantError: "column email is not defined in the schema",
+		},
+		{
+			name:      "ambiguous column",
+			input:     `SELECT dept_no FROM persons JOIN depts USING (dept_no)`,
+			wantError: "column reference dept_no is ambiguous",
+		},
+		{
+			name:      "no name",
+			input:     `SELECT budget * 2 FROM depts`,
+			wantError: `select item "budget * 2" has no name, add an alias`,
+		},
+		{
+			name:      "duplicate column",
+			input:     `SELECT * FROM persons, depts`,
+			wantError: "duplicate column dept_no in the select list, add an alias",
+		},
+		{
+			name:      "subquery",
+			input:     `SELECT * FROM (SELECT 1) t`,
+			wantError: "subqueries in FROM are not supported",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, _, err := resolveColumns(s, tt.input)
+			if err == nil {
+				t.Fatal("should return error")
+			}
+			if err.Error() != tt.wantError {
+				t.Errorf("\nexpected:\n%v\nbut got\n%v\n", tt.wantError, err.Error())
+			}
+		})
+	}
+
+	// 行を返さない文と、WITHで定義した問い合わせを参照する文は解決しない
+	for _, input := range []string{
+		`UPDATE persons SET active = false`,
+		`WITH x AS (SELECT first_name FROM persons) SELECT first_name FROM x`,
+		`WITH RECURSIVE t (n) AS (SELECT 1), x AS MATERIALIZED (SELECT 2) SELECT p.active FROM persons p JOIN x ON true`,
+	} {
+		if _, ok, err := resolveColumns(s, input); ok || err != nil {
+			t.Errorf("%s: should not resolve: %v, %v", input, ok, err)
+		}
+	}
+}
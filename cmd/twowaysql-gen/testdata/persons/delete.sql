@@ -0,0 +1,7 @@
+DELETE FROM
+	persons
+WHERE
+	employee_no = /*EmpNo*/1
+RETURNING
+	employee_no,
+	first_name
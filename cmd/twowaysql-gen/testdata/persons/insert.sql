@@ -0,0 +1,5 @@
+-- 社員をまとめて登録する
+INSERT INTO persons (employee_no, dept_no, first_name, email) VALUES
+/* FOR m IN members SEPARATOR ',' */
+	(/*m.EmpNo*/1, /*deptNo*/1, /*m.name*/'Tim', /*m.email*/'tim@example.com')
+/* END */
@@ -0,0 +1,4 @@
+WITH recent AS (
+	SELECT employee_no, first_name FROM persons WHERE employee_no > /*minEmpNo*/100
+)
+SELECT first_name FROM recent ORDER BY employee_no
@@ -0,0 +1,14 @@
+SELECT
+	*
+FROM
+	persons
+WHERE
+	dept_no IN /*deptNos*/(3, 5, 7)
+	/* IF gender_list !== null */
+	AND gender IN /*gender_list*/('M')
+	/* END */
+	/* IF dept.name === "HR" && active */
+	AND dept_no = /*dept.no*/1
+	/* END */
+ORDER BY
+	/*$sortColumn*/employee_no
@@ -0,0 +1,166 @@
+package main
+
+import (
+	"database/sql"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/future-architect/go-twowaysql"
+	"gopkg.in/yaml.v3"
+)
+
+var placeholders = map[string]twowaysql.Placeholder{
+	"question": twowaysql.PlaceholderQuestion,
+	"dollar":   twowaysql.PlaceholderDollar,
+	"colon":    twowaysql.PlaceholderColon,
+	"at":       twowaysql.PlaceholderAt,
+	"named":    twowaysql.PlaceholderNamed,
+}
+
+func runEval(args []string, stdin io.Reader, stdout, stderr io.Writer) error {
+	fs := flag.NewFlagSet("eval", flag.ContinueOnError)
+	fs.SetOutput(stderr)
+	paramsFile := fs.String("params", "", "JSON or YAML file of the parameters, - for stdin")
+	inline := fs.Bool("inline", false, "write the bind values into the query as literals")
+	placeholder := fs.String("placeholder", "question", "placeholder style: question, dollar, colon, at or named")
+	name := fs.String("name", "", "evaluate the query with this -- name: in the file")
+	fs.Usage = func() {
+		fmt.Fprintf(fs.Output(), "usage: twowaysql eval [flags] file.sql\n")
+		fs.PrintDefaults()
+	}
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if fs.NArg() != 1 {
+		fs.Usage()
+		return flag.ErrHelp
+	}
+	style, ok := placeholders[*placeholder]
+	if !ok {
+		return fmt.Errorf("unknown placeholder style %q", *placeholder)
+	}
+
+	opts := []twowaysql.Option{twowaysql.WithPlaceholder(style)}
+	if *inline {
+		// プレースホルダの代わりに値のリテラルを書く
+		opts = []twowaysql.Option{twowaysql.WithPlaceholderFunc(func(_ int, value interface{}) string {
+			return literal(value)
+		})}
+	}
+	tmpl, err := loadTemplate(fs.Arg(0), *name, opts...)
+	if err != nil {
+		return err
+	}
+	params, err := readParams(*paramsFile, stdin)
+	if err != nil {
+		return err
+	}
+
+	query, values, err := evalTemplate(tmpl, params, style)
+	if err != nil {
+		return err
+	}
+	if _, err := fmt.Fprintln(stdout, query); err != nil {
+		return err
+	}
+	if *inline {
+		return nil
+	}
+	for i, v := range values {
+		label := strconv.Itoa(i + 1)
+		if arg, ok := v.(sql.NamedArg); ok {
+			label, v = arg.Name, arg.Value
+		}
+		if _, err := fmt.Fprintf(stdout, "-- %s: %s\n", label, literal(v)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// loadTemplateはSQLファイルを読み込む。nameが指定されていれば-- name:で区切られたクエリから探す
+func loadTemplate(path, name string, opts ...twowaysql.Option) (*twowaysql.Template, error) {
+	src, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	if name == "" {
+		tmpl, err := twowaysql.Compile(string(src), opts...)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", path, err)
+		}
+		return tmpl, nil
+	}
+	registry := twowaysql.NewRegistry(opts...)
+	if err := registry.Parse(path, string(src)); err != nil {
+		return nil, err
+	}
+	return registry.Lookup(name)
+}
+
+// readParamsはパラメータのファイルを読み込む。YAMLはJSONを含むのでどちらもYAMLとして読む
+func readParams(path string, stdin io.Reader) (map[string]interface{}, error) {
+	if path == "" {
+		return map[string]interface{}{}, nil
+	}
+	var src []byte
+	var err error
+	if path == "-" {
+		src, err = io.ReadAll(stdin)
+	} else {
+		src, err = os.ReadFile(path)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	params := map[string]interface{}{}
+	if err := yaml.Unmarshal(src, &params); err != nil {
+		return nil, fmt.Errorf("parse %s: %w", path, err)
+	}
+	return params, nil
+}
+
+// evalTemplateはテンプレートを評価する。プレースホルダの形式はloadTemplateで指定したものになる
+func evalTemplate(tmpl *twowaysql.Template, params map[string]interface{}, style twowaysql.Placeholder) (string, []interface{}, error) {
+	if style == twowaysql.PlaceholderNamed {
+		query, args, err := tmpl.EvalNamed(params)
+		values := make([]interface{}, len(args))
+		for i, arg := range args {
+			values[i] = arg
+		}
+		return query, values, err
+	}
+	return tmpl.Eval(params)
+}
+
+// literalは値をSQLのリテラルとして書く
+func literal(v interface{}) string {
+	switch v := v.(type) {
+	case nil:
+		return "NULL"
+	case bool:
+		if v {
+			return "TRUE"
+		}
+		return "FALSE"
+	case int, int8, int16, int32, int64, uint, uint8, uint16, uint32, uint64, float32, float64:
+		return fmt.Sprint(v)
+	case string:
+		return quote(v)
+	case []byte:
+		return quote(string(v))
+	case time.Time:
+		return quote(v.Format("2006-01-02 15:04:05.999999999Z07:00"))
+	}
+	return quote(fmt.Sprint(v))
+}
+
+func quote(s string) string {
+	return "'" + strings.ReplaceAll(s, "'", "''") + "'"
+}
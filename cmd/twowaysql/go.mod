@@ -0,0 +1,16 @@
+module github.com/future-architect/go-twowaysql/cmd/twowaysql
+
+go 1.23
+
+require (
+	github.com/future-architect/go-twowaysql v0.0.0-20261017034057-950e63f22829
+	gopkg.in/yaml.v3 v3.0.1
+)
+
+require (
+	github.com/jmoiron/sqlx v1.3.1 // indirect
+	gitlab.com/osaki-lab/tagscanner v0.1.2 // indirect
+)
+
+// ローカルで開発するときは親ディレクトリを使う。依存として使われるときはreplaceは無視され、上のバージョンが使われる
+replace github.com/future-architect/go-twowaysql => ../../
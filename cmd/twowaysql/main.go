@@ -0,0 +1,57 @@
+// Command twowaysql is a tool for 2-way SQL templates.
+//
+// Usage:
+//
+//	twowaysql eval [flags] file.sql
+//
+// The eval subcommand evaluates a 2-way SQL file with the parameters in a JSON or YAML file,
+// and prints the query and the bind values in order, as Eval returns them:
+//
+//	$ twowaysql eval -params params.yaml persons/find.sql
+//	SELECT first_name, last_name, email FROM persons WHERE employee_no < ?/*maxEmpNo*/ AND dept_no < ?/*deptNo*/ ORDER BY employee_no
+//	-- 1: 3
+//	-- 2: 12
+//
+// With -inline, the bind values are written into the query as literals, so that it can be pasted into psql.
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+)
+
+const usage = `usage: twowaysql <command> [arguments]
+
+commands:
+  eval    evaluate a 2-way SQL file and print the query and the bind values
+
+Run "twowaysql <command> -h" for the flags of a command.
+`
+
+func main() {
+	if err := run(os.Args[1:], os.Stdin, os.Stdout, os.Stderr); err != nil {
+		if errors.Is(err, flag.ErrHelp) {
+			os.Exit(2)
+		}
+		fmt.Fprintf(os.Stderr, "twowaysql: %v\n", err)
+		os.Exit(1)
+	}
+}
+
+func run(args []string, stdin io.Reader, stdout, stderr io.Writer) error {
+	if len(args) == 0 {
+		fmt.Fprint(stderr, usage)
+		return flag.ErrHelp
+	}
+	switch args[0] {
+	case "eval":
+		return runEval(args[1:], stdin, stdout, stderr)
+	case "help", "-h", "-help", "--help":
+		fmt.Fprint(stdout, usage)
+		return nil
+	}
+	return fmt.Errorf("unknown command %q\n\n%s", args[0], usage)
+}
@@ -0,0 +1,164 @@
+package main
+
+import (
+	"bytes"
+	"io"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestEval(t *testing.T) {
+	tests := []struct {
+		name  string
+		args  []string
+		stdin string
+		want  string
+	}{
+		{
+			name: "yaml",
+			args: []string{"eval", "-params", "testdata/params.yaml", "../../testdata/sql/persons/find.sql"},
+			want: `SELECT first_name, last_name, email FROM persons WHERE employee_no < ?/*maxEmpNo*/ AND dept_no < ?/*deptNo*/ ORDER BY employee_no
+-- 1: 3
+-- 2: 12
+`,
+		},
+		{
+			name: "json",
+			args: []string{"eval", "-params", "testdata/params.json", "-placeholder", "dollar", "testdata/search.sql"},
+			want: `SELECT * FROM persons WHERE first_name = $1/*name*/ AND dept_no IN ($2, $3)/*deptNos*/ AND email <> '$1'
+-- 1: 'O''Brien'
+-- 2: 10
+-- 3: 11
+`,
+		},
+		{
+			name: "named",
+			args: []string{"eval", "-params", "testdata/params.json", "-placeholder", "named", "testdata/search.sql"},
+			want: `SELECT * FROM persons WHERE first_name = :name/*name*/ AND dept_no IN (:deptNos, :deptNos_2)/*deptNos*/ AND email <> '$1'
+-- name: 'O''Brien'
+-- deptNos: 10
+-- deptNos_2: 11
+`,
+		},
+		{
+			name: "inline",
+			args: []string{"eval", "-params", "testdata/params.json", "-inline", "testdata/search.sql"},
+			want: `SELECT * FROM persons WHERE first_name = 'O''Brien'/*name*/ AND dept_no IN (10, 11)/*deptNos*/ AND email <> '$1'
+`,
+		},
+		{
+			name:  "inline embedded value",
+			args:  []string{"eval", "-params", "-", "-inline", "testdata/embed.sql"},
+			stdin: `{"column": "a$1", "deptNo": 3}`,
+			want: `SELECT a$1/*$column*/ FROM persons WHERE dept_no = 3/*deptNo*/
+`,
+		},
+		{
+			name:  "stdin and named query",
+			args:  []string{"eval", "-params", "-", "-name", "UpdatePersonName", "-inline", "../../testdata/sql/named/persons.sql"},
+			stdin: `{"firstName": "Jon", "EmpNo": 1}`,
+			want: `UPDATE persons SET first_name = 'Jon'/*firstName*/ WHERE employee_no = 1/*EmpNo*/
+`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var out bytes.Buffer
+			if err := run(tt.args, strings.NewReader(tt.stdin), &out, io.Discard); err != nil {
+				t.Fatal(err)
+			}
+			if got := out.String(); got != tt.want {
+				t.Errorf("Doesn't Match\nexpected: \n%s\n but got: \n%s\n", tt.want, got)
+			}
+		})
+	}
+}
+
+func TestEvalAbnormal(t *testing.T) {
+	tests := []struct {
+		name      string
+		args      []string
+		wantError string
+		wantUsage string
+	}{
+		{
+			name:      "no command",
+			args:      []string{},
+			wantError: "flag: help requested",
+			wantUsage: "usage: twowaysql <command> [arguments]",
+		},
+		{
+			name:      "no file",
+			args:      []string{"eval"},
+			wantError: "flag: help requested",
+			wantUsage: "usage: twowaysql eval [flags] file.sql",
+		},
+		{
+			name:      "unknown command",
+			args:      []string{"compile"},
+			wantError: `unknown command "compile"`,
+		},
+		{
+			name:      "missing parameter",
+			args:      []string{"eval", "testdata/search.sql"},
+			wantError: `can not evaluate condition "dept.active": undefined variable: dept.active`,
+		},
+		{
+			name:      "unknown placeholder",
+			args:      []string{"eval", "-placeholder", "percent", "testdata/search.sql"},
+			wantError: `unknown placeholder style "percent"`,
+		},
+		{
+			name:      "unknown name",
+			args:      []string{"eval", "-name", "DeletePerson", "../../testdata/sql/named/persons.sql"},
+			wantError: "twowaysql: query not found: DeletePerson",
+		},
+		{
+			name:      "broken params",
+			args:      []string{"eval", "-params", "testdata/search.sql", "testdata/search.sql"},
+			wantError: "parse testdata/search.sql: ",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var out, errOut bytes.Buffer
+			err := run(tt.args, strings.NewReader(""), &out, &errOut)
+			if err == nil {
+				t.Fatal("should return error")
+			}
+			if !strings.HasPrefix(err.Error(), tt.wantError) {
+				t.Errorf("\nexpected:\n%v\nbut got\n%v\n", tt.wantError, err.Error())
+			}
+			// 使い方はstderrに渡したWriterに書く
+			if !strings.HasPrefix(errOut.String(), tt.wantUsage) {
+				t.Errorf("\nexpected:\n%v\nbut got\n%v\n", tt.wantUsage, errOut.String())
+			}
+		})
+	}
+}
+
+func TestLiteral(t *testing.T) {
+	tests := []struct {
+		input interface{}
+		want  string
+	}{
+		{input: nil, want: "NULL"},
+		{input: true, want: "TRUE"},
+		{input: 12, want: "12"},
+		{input: 1.5, want: "1.5"},
+		{input: "it's", want: "'it''s'"},
+		{input: []byte("abc"), want: "'abc'"},
+		{input: time.Date(2021, 1, 2, 3, 4, 5, 0, time.UTC), want: "'2021-01-02 03:04:05Z'"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.want, func(t *testing.T) {
+			if got := literal(tt.input); got != tt.want {
+				t.Errorf("Doesn't Match\nexpected: %s\n but got: %s\n", tt.want, got)
+			}
+		})
+	}
+}
@@ -0,0 +1 @@
+SELECT /*$column*/name FROM persons WHERE dept_no = /*deptNo*/1
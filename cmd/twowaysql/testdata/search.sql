@@ -0,0 +1,10 @@
+SELECT
+	*
+FROM
+	persons
+WHERE
+	first_name = /*name*/'Tim'
+	AND dept_no IN /*deptNos*/(1, 2)
+	/* IF dept.active */
+	AND email <> '$1'
+	/* END */
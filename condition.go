@@ -0,0 +1,548 @@
+package twowaysql
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+// condition is a compiled expression of /* IF ... */ and /* ELIF ... */.
+//
+// The expression language supports:
+//
+//	literals:    123, 1.5, "str", 'str', true, false, null (nil)
+//	variables:   name, name.field.field
+//	comparisons: ==, !=, ===, !==, <, <=, >, >=
+//	logical:     &&, ||, !
+//	membership:  x in list, x in [1, 2, 3]
+//	functions:   len(x)
+//
+// A variable alone is evaluated by its truthiness:
+// null, false, 0, "" and empty slices or maps are false.
+type condition struct {
+	src  string
+	root exprNode
+}
+
+// compileCondition parses the expression of IF/ELIF.
+func compileCondition(src string) (*condition, error) {
+	p := &exprParser{src: src}
+	if err := p.lex(); err != nil {
+		return nil, fmt.Errorf("can not parse condition %q: %w", src, err)
+	}
+	root, err := p.parseOr()
+	if err == nil && p.peek().kind != etEOF {
+		err = fmt.Errorf("unexpected %q", p.peek().str)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("can not parse condition %q: %w", src, err)
+	}
+	return &condition{src: src, root: root}, nil
+}
+
+// Evaluate returns the truthiness of the condition against params.
+func (c *condition) Evaluate(params map[string]interface{}) (bool, error) {
+	v, err := c.root.eval(params)
+	if err != nil {
+		return false, fmt.Errorf("can not evaluate condition %q: %w", c.src, err)
+	}
+	return truthy(v), nil
+}
+
+// 条件式の字句解析
+
+type exprTokenKind int
+
+const (
+	etEOF exprTokenKind = iota
+	etIdent
+	etNumber
+	etString
+	etOperator
+)
+
+type exprToken struct {
+	kind  exprTokenKind
+	str   string
+	value interface{} /* for Number and String */
+}
+
+// 長いものから順に並べる
+var exprOperators = []string{
+	"===", "!==",
+	"==", "!=", "<=", ">=", "&&", "||",
+	"<", ">", "!", "(", ")", "[", "]", ",", ".", "-",
+}
+
+type exprParser struct {
+	src    string
+	tokens []exprToken
+	pos    int
+}
+
+func (p *exprParser) lex() error {
+	src := p.src
+	i := 0
+loop:
+	for i < len(src) {
+		c := src[i]
+		switch {
+		case c == ' ' || c == '\t' || c == '\n' || c == '\r':
+			i++
+		case c == '"' || c == '\'':
+			// 文字列リテラル
+			var b strings.Builder
+			j := i + 1
+			for ; j < len(src) && src[j] != c; j++ {
+				if src[j] == '\\' && j+1 < len(src) {
+					j++
+				}
+				b.WriteByte(src[j])
+			}
+			if j >= len(src) {
+				return fmt.Errorf("unterminated string literal")
+			}
+			p.tokens = append(p.tokens, exprToken{kind: etString, str: src[i : j+1], value: b.String()})
+			i = j + 1
+		case c >= '0' && c <= '9':
+			j := i
+			isFloat := false
+			for j < len(src) && (src[j] >= '0' && src[j] <= '9' || src[j] == '.' && !isFloat) {
+				if src[j] == '.' {
+					isFloat = true
+				}
+				j++
+			}
+			str := src[i:j]
+			var value interface{}
+			var err error
+			if isFloat {
+				value, err = strconv.ParseFloat(str, 64)
+			} else {
+				value, err = strconv.ParseInt(str, 10, 64)
+			}
+			if err != nil {
+				return fmt.Errorf("invalid number %q", str)
+			}
+			p.tokens = append(p.tokens, exprToken{kind: etNumber, str: str, value: value})
+			i = j
+		case c == '_' || c == '$' || unicode.IsLetter(rune(c)) || c >= 0x80:
+			j := i
+			for j < len(src) {
+				r := rune(src[j])
+				if r == '_' || r == '$' || unicode.IsLetter(r) || unicode.IsDigit(r) || r >= 0x80 {
+					j++
+					continue
+				}
+				break
+			}
+			p.tokens = append(p.tokens, exprToken{kind: etIdent, str: src[i:j]})
+			i = j
+		default:
+			for _, op := range exprOperators {
+				if strings.HasPrefix(src[i:], op) {
+					p.tokens = append(p.tokens, exprToken{kind: etOperator, str: op})
+					i += len(op)
+					continue loop
+				}
+			}
+			return fmt.Errorf("unexpected character %q", c)
+		}
+	}
+	p.tokens = append(p.tokens, exprToken{kind: etEOF})
+	return nil
+}
+
+// 条件式の構文解析
+// 生成規則:
+// or      = and ("||" and)*
+// and     = equality ("&&" equality)*
+// equality = relational (("==" | "!=" | "===" | "!==") relational)*
+// relational = unary (("<" | "<=" | ">" | ">=" | "in") unary)*
+// unary   = ("!" | "-") unary | postfix
+// postfix = primary ("." ident)*
+// primary = number | string | "true" | "false" | "null" | "nil"
+//			| ident "(" args ")" | ident | "(" or ")" | "[" args "]"
+
+func (p *exprParser) peek() exprToken {
+	return p.tokens[p.pos]
+}
+
+func (p *exprParser) next() exprToken {
+	tok := p.tokens[p.pos]
+	if tok.kind != etEOF {
+		p.pos++
+	}
+	return tok
+}
+
+// consumeOpは次のトークンが所望の演算子であれば読み進める
+func (p *exprParser) consumeOp(ops ...string) (string, bool) {
+	tok := p.peek()
+	if tok.kind != etOperator && !(tok.kind == etIdent && tok.str == "in") {
+		return "", false
+	}
+	for _, op := range ops {
+		if tok.str == op {
+			p.pos++
+			return op, true
+		}
+	}
+	return "", false
+}
+
+func (p *exprParser) expectOp(op string) error {
+	if _, ok := p.consumeOp(op); !ok {
+		return p.unexpected(fmt.Sprintf("%q", op))
+	}
+	return nil
+}
+
+func (p *exprParser) unexpected(want string) error {
+	tok := p.peek()
+	if tok.kind == etEOF {
+		return fmt.Errorf("expected %s, but got end of condition", want)
+	}
+	return fmt.Errorf("expected %s, but got %q", want, tok.str)
+}
+
+func (p *exprParser) parseOr() (exprNode, error) {
+	x, err := p.parseAnd()
+	if err != nil {
+		return nil, err
+	}
+	for {
+		if _, ok := p.consumeOp("||"); !ok {
+			return x, nil
+		}
+		y, err := p.parseAnd()
+		if err != nil {
+			return nil, err
+		}
+		x = &logicalExpr{or: true, x: x, y: y}
+	}
+}
+
+func (p *exprParser) parseAnd() (exprNode, error) {
+	x, err := p.parseEquality()
+	if err != nil {
+		return nil, err
+	}
+	for {
+		if _, ok := p.consumeOp("&&"); !ok {
+			return x, nil
+		}
+		y, err := p.parseEquality()
+		if err != nil {
+			return nil, err
+		}
+		x = &logicalExpr{x: x, y: y}
+	}
+}
+
+func (p *exprParser) parseEquality() (exprNode, error) {
+	x, err := p.parseRelational()
+	if err != nil {
+		return nil, err
+	}
+	for {
+		op, ok := p.consumeOp("==", "!=", "===", "!==")
+		if !ok {
+			return x, nil
+		}
+		y, err := p.parseRelational()
+		if err != nil {
+			return nil, err
+		}
+		x = &binaryExpr{op: op, x: x, y: y}
+	}
+}
+
+func (p *exprParser) parseRelational() (exprNode, error) {
+	x, err := p.parseUnary()
+	if err != nil {
+		return nil, err
+	}
+	for {
+		op, ok := p.consumeOp("<", "<=", ">", ">=", "in")
+		if !ok {
+			return x, nil
+		}
+		y, err := p.parseUnary()
+		if err != nil {
+			return nil, err
+		}
+		x = &binaryExpr{op: op, x: x, y: y}
+	}
+}
+
+func (p *exprParser) parseUnary() (exprNode, error) {
+	if op, ok := p.consumeOp("!", "-"); ok {
+		x, err := p.parseUnary()
+		if err != nil {
+			return nil, err
+		}
+		return &unaryExpr{op: op, x: x}, nil
+	}
+	return p.parsePostfix()
+}
+
+func (p *exprParser) parsePostfix() (exprNode, error) {
+	x, err := p.parsePrimary()
+	if err != nil {
+		return nil, err
+	}
+	for {
+		if _, ok := p.consumeOp("."); !ok {
+			return x, nil
+		}
+		tok := p.peek()
+		if tok.kind != etIdent {
+			return nil, p.unexpected("field name")
+		}
+		p.pos++
+		if v, ok := x.(*varExpr); ok {
+			v.path = append(v.path, tok.str)
+			continue
+		}
+		x = &fieldExpr{x: x, name: tok.str}
+	}
+}
+
+func (p *exprParser) parsePrimary() (exprNode, error) {
+	tok := p.next()
+	switch tok.kind {
+	case etNumber, etString:
+		return &literalExpr{value: tok.value}, nil
+	case etIdent:
+		switch tok.str {
+		case "true":
+			return &literalExpr{value: true}, nil
+		case "false":
+			return &literalExpr{value: false}, nil
+		case "null", "nil":
+			return &literalExpr{value: nil}, nil
+		}
+		if _, ok := p.consumeOp("("); ok {
+			args, err := p.parseArgs(")")
+			if err != nil {
+				return nil, err
+			}
+			return newCallExpr(tok.str, args)
+		}
+		return &varExpr{path: []string{tok.str}}, nil
+	case etOperator:
+		switch tok.str {
+		case "(":
+			x, err := p.parseOr()
+			if err != nil {
+				return nil, err
+			}
+			if err := p.expectOp(")"); err != nil {
+				return nil, err
+			}
+			return x, nil
+		case "[":
+			elems, err := p.parseArgs("]")
+			if err != nil {
+				return nil, err
+			}
+			return &listExpr{elems: elems}, nil
+		}
+	}
+	if tok.kind != etEOF {
+		p.pos--
+	}
+	return nil, p.unexpected("operand")
+}
+
+// parseArgsは閉じ括弧までの,区切りの式を読む
+func (p *exprParser) parseArgs(closing string) ([]exprNode, error) {
+	var args []exprNode
+	if _, ok := p.consumeOp(closing); ok {
+		return args, nil
+	}
+	for {
+		arg, err := p.parseOr()
+		if err != nil {
+			return nil, err
+		}
+		args = append(args, arg)
+		if _, ok := p.consumeOp(","); ok {
+			continue
+		}
+		if err := p.expectOp(closing); err != nil {
+			return nil, err
+		}
+		return args, nil
+	}
+}
+
+// 条件式の評価
+
+type exprNode interface {
+	eval(params map[string]interface{}) (interface{}, error)
+}
+
+type literalExpr struct {
+	value interface{}
+}
+
+func (e *literalExpr) eval(params map[string]interface{}) (interface{}, error) {
+	return e.value, nil
+}
+
+// varExpr is a reference to a parameter such as name or name.field.
+type varExpr struct {
+	path []string
+}
+
+func (e *varExpr) eval(params map[string]interface{}) (interface{}, error) {
+	v, err := lookupNames(func(name string) (interface{}, bool) {
+		v, ok := params[name]
+		return v, ok
+	}, e.path)
+	if err != nil {
+		return nil, fmt.Errorf("undefined variable: %s", describeMissing(strings.Join(e.path, "."), err))
+	}
+	return v, nil
+}
+
+// fieldExpr is a field access to the result of an expression other than a variable.
+type fieldExpr struct {
+	x    exprNode
+	name string
+}
+
+func (e *fieldExpr) eval(params map[string]interface{}) (interface{}, error) {
+	x, err := e.x.eval(params)
+	if err != nil {
+		return nil, err
+	}
+	v, ok := fieldValue(x, e.name)
+	if !ok {
+		return nil, fmt.Errorf("undefined field: %s", e.name)
+	}
+	return v, nil
+}
+
+type listExpr struct {
+	elems []exprNode
+}
+
+func (e *listExpr) eval(params map[string]interface{}) (interface{}, error) {
+	list := make([]interface{}, 0, len(e.elems))
+	for _, elem := range e.elems {
+		v, err := elem.eval(params)
+		if err != nil {
+			return nil, err
+		}
+		list = append(list, v)
+	}
+	return list, nil
+}
+
+type callExpr struct {
+	arg exprNode
+}
+
+func newCallExpr(name string, args []exprNode) (exprNode, error) {
+	// 組み込み関数はlenのみ
+	if name != "len" {
+		return nil, fmt.Errorf("unknown function: %s", name)
+	}
+	if len(args) != 1 {
+		return nil, fmt.Errorf("len: expected 1 argument, but got %d", len(args))
+	}
+	return &callExpr{arg: args[0]}, nil
+}
+
+func (e *callExpr) eval(params map[string]interface{}) (interface{}, error) {
+	v, err := e.arg.eval(params)
+	if err != nil {
+		return nil, err
+	}
+	return length(v)
+}
+
+type unaryExpr struct {
+	op string
+	x  exprNode
+}
+
+func (e *unaryExpr) eval(params map[string]interface{}) (interface{}, error) {
+	x, err := e.x.eval(params)
+	if err != nil {
+		return nil, err
+	}
+	if e.op == "!" {
+		return !truthy(x), nil
+	}
+	switch v := normalize(x).(type) {
+	case int64:
+		return -v, nil
+	case float64:
+		return -v, nil
+	}
+	return nil, fmt.Errorf("operator -: unsupported type %T", x)
+}
+
+type logicalExpr struct {
+	or   bool
+	x, y exprNode
+}
+
+func (e *logicalExpr) eval(params map[string]interface{}) (interface{}, error) {
+	x, err := e.x.eval(params)
+	if err != nil {
+		return nil, err
+	}
+	// 短絡評価
+	if truthy(x) == e.or {
+		return e.or, nil
+	}
+	y, err := e.y.eval(params)
+	if err != nil {
+		return nil, err
+	}
+	return truthy(y), nil
+}
+
+type binaryExpr struct {
+	op   string
+	x, y exprNode
+}
+
+func (e *binaryExpr) eval(params map[string]interface{}) (interface{}, error) {
+	x, err := e.x.eval(params)
+	if err != nil {
+		return nil, err
+	}
+	y, err := e.y.eval(params)
+	if err != nil {
+		return nil, err
+	}
+	switch e.op {
+	case "==", "===":
+		return equal(x, y), nil
+	case "!=", "!==":
+		return !equal(x, y), nil
+	case "in":
+		return contains(y, x)
+	}
+	c, ok := compare(x, y)
+	if !ok {
+		return nil, fmt.Errorf("operator %s: can not compare %T and %T", e.op, x, y)
+	}
+	switch e.op {
+	case "<":
+		return c < 0, nil
+	case "<=":
+		return c <= 0, nil
+	case ">":
+		return c > 0, nil
+	default:
+		return c >= 0, nil
+	}
+}
@@ -0,0 +1,173 @@
+package twowaysql
+
+import (
+	"database/sql"
+	"testing"
+)
+
+func TestConditionEvaluate(t *testing.T) {
+	type Dept struct {
+		No   int    `twowaysql:"no"`
+		Name string `twowaysql:"name"`
+	}
+	var nilDept *Dept
+	params := map[string]interface{}{
+		"name":      "HR",
+		"deptNo":    15,
+		"rate":      1.5,
+		"checked":   true,
+		"unchecked": false,
+		"zero":      0,
+		"nil":       nil,
+		"int_list":  []int{1, 2, 3},
+		"empty":     []string{},
+		"nil_list":  []int(nil),
+		"dept":      &Dept{No: 10, Name: "Sales"},
+		"nil_dept":  nilDept,
+		"attrs":     map[string]interface{}{"gender": "M"},
+		"null_str":  sql.NullString{},
+		"valid_str": sql.NullString{String: "a", Valid: true},
+	}
+
+	tests := []struct {
+		name  string
+		input string
+		want  bool
+	}{
+		{name: "true", input: "true", want: true},
+		{name: "false", input: "false", want: false},
+		{name: "truthy string", input: "name", want: true},
+		{name: "truthy int", input: "deptNo", want: true},
+		{name: "falsy int", input: "zero", want: false},
+		{name: "falsy nil", input: "nil", want: false},
+		{name: "falsy empty slice", input: "empty", want: false},
+		{name: "strict equal", input: "deptNo === 15", want: true},
+		{name: "strict not equal", input: "deptNo !== 15", want: false},
+		{name: "equal", input: "deptNo == 15", want: true},
+		{name: "not equal", input: "deptNo != 15", want: false},
+		{name: "int float", input: "rate > deptNo", want: false},
+		{name: "float literal", input: "rate == 1.5", want: true},
+		{name: "negative", input: "zero > -1", want: true},
+		{name: "less equal", input: "deptNo <= 15", want: true},
+		{name: "greater equal", input: "deptNo >= 16", want: false},
+		{name: "string double quote", input: `name === "HR"`, want: true},
+		{name: "string single quote", input: `name === 'GA'`, want: false},
+		{name: "string less than", input: `name < "ZZ"`, want: true},
+		{name: "null slice", input: "int_list !== null", want: true},
+		{name: "null nil slice", input: "nil_list == null", want: true},
+		{name: "null nil", input: "nil == nil", want: true},
+		{name: "null nil pointer", input: "nil_dept == null", want: true},
+		{name: "null valuer", input: "null_str == null", want: true},
+		{name: "valuer", input: `valid_str == "a"`, want: true},
+		{name: "and", input: "checked && deptNo > 10", want: true},
+		{name: "or", input: "unchecked || zero", want: false},
+		{name: "not", input: "!unchecked", want: true},
+		{name: "precedence", input: "checked || unchecked && false", want: true},
+		{name: "paren", input: "(checked || unchecked) && false", want: false},
+		{name: "short circuit", input: "nil_dept != null && nil_dept.no > 1", want: false},
+		{name: "in slice", input: "2 in int_list", want: true},
+		{name: "not in slice", input: "!(5 in int_list)", want: true},
+		{name: "in list literal", input: `name in ["HR", "GA"]`, want: true},
+		{name: "in map", input: `"gender" in attrs`, want: true},
+		{name: "len slice", input: "len(int_list) == 3", want: true},
+		{name: "len string", input: "len(name) > 2", want: false},
+		{name: "len nil", input: "len(nil_list) == 0", want: true},
+		{name: "field by tag", input: "dept.no == 10", want: true},
+		{name: "field by name", input: `dept.Name == "Sales"`, want: true},
+		{name: "map key", input: `attrs.gender == "M"`, want: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cond, err := compileCondition(tt.input)
+			if err != nil {
+				t.Fatal(err)
+			}
+			got, err := cond.Evaluate(params)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got != tt.want {
+				t.Errorf("Doesn't Match\nexpected: %v\n but got: %v\n", tt.want, got)
+			}
+		})
+	}
+}
+
+func TestConditionAbnormal(t *testing.T) {
+	type employee struct {
+		*Dept
+	}
+	params := map[string]interface{}{
+		"name":     "HR",
+		"deptNo":   15,
+		"employee": employee{},
+	}
+
+	tests := []struct {
+		name      string
+		input     string
+		wantError string
+	}{
+		{
+			name:      "unterminated string",
+			input:     `name == "HR`,
+			wantError: `can not parse condition "name == \"HR": unterminated string literal`,
+		},
+		{
+			name:      "missing operand",
+			input:     `deptNo ==`,
+			wantError: `can not parse condition "deptNo ==": expected operand, but got end of condition`,
+		},
+		{
+			name:      "missing paren",
+			input:     `(deptNo == 1`,
+			wantError: `can not parse condition "(deptNo == 1": expected ")", but got end of condition`,
+		},
+		{
+			name:      "extra token",
+			input:     `deptNo 1`,
+			wantError: `can not parse condition "deptNo 1": unexpected "1"`,
+		},
+		{
+			name:      "unknown function",
+			input:     `size(name)`,
+			wantError: `can not parse condition "size(name)": unknown function: size`,
+		},
+		{
+			name:      "undefined variable",
+			input:     `empNo == 1`,
+			wantError: `can not evaluate condition "empNo == 1": undefined variable: empNo`,
+		},
+		{
+			name:      "undefined field",
+			input:     `name.first == 1`,
+			wantError: `can not evaluate condition "name.first == 1": undefined variable: name.first (name has no field first)`,
+		},
+		{
+			name:      "field of nil embedded pointer",
+			input:     `employee.No == 1`,
+			wantError: `can not evaluate condition "employee.No == 1": undefined variable: employee.No (employee has no field No)`,
+		},
+		{
+			name:      "can not compare",
+			input:     `name < 1`,
+			wantError: `can not evaluate condition "name < 1": operator <: can not compare string and int64`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cond, err := compileCondition(tt.input)
+			if err == nil {
+				_, err = cond.Evaluate(params)
+			}
+			if err == nil {
+				t.Fatal("should return error")
+			}
+			if err.Error() != tt.wantError {
+				t.Errorf("\nexpected:\n%v\nbut got\n%v\n", tt.wantError, err.Error())
+			}
+		})
+	}
+}
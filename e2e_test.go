@@ -2,8 +2,10 @@ package twowaysql
 
 import (
 	"context"
+	"database/sql"
 	"errors"
 	"fmt"
+	"io/fs"
 	"os"
 	"testing"
 
@@ -56,6 +58,621 @@ func TestSelect(t *testing.T) {
 
 }
 
+func TestSelectTemplate(t *testing.T) {
+	//このテストはinit.sqlに依存しています。
+	//データベースは/postgres/init以下のsqlファイルを用いて初期化されている。
+	db := open(t)
+	defer db.Close()
+	tw := New(db)
+	ctx := context.Background()
+
+	tmpl := MustCompile(`SELECT first_name, last_name, email FROM persons WHERE employee_no < /*maxEmpNo*/1000 /* IF deptNo */ AND dept_no < /*deptNo*/1 /* END */ ORDER BY employee_no`)
+
+	var people []Person
+	err := tw.SelectTemplate(ctx, &people, tmpl, &Info{MaxEmpNo: 3, DeptNo: 12})
+	if err != nil {
+		t.Fatalf("select: failed: %v", err)
+	}
+	expected := []Person{
+		{
+			FirstName: "Evan",
+			LastName:  "MacMans",
+			Email:     "evanmacmans@example.com",
+		},
+		{
+			FirstName: "Malvina",
+			LastName:  "FitzSimons",
+			Email:     "malvinafitzsimons@example.com",
+		},
+	}
+	if !match(people, expected) {
+		t.Errorf("\nexpected:\n%v\nbut got\n%v\n", expected, people)
+	}
+
+	// 同じテンプレートを別のパラメータで再利用する
+	people = []Person{}
+	err = tw.SelectTemplate(ctx, &people, tmpl, &Info{MaxEmpNo: 2})
+	if err != nil {
+		t.Fatalf("select: failed: %v", err)
+	}
+	expected = expected[:1]
+	if !match(people, expected) {
+		t.Errorf("\nexpected:\n%v\nbut got\n%v\n", expected, people)
+	}
+}
+
+func TestGet(t *testing.T) {
+	//このテストはinit.sqlに依存しています。
+	//データベースは/postgres/init以下のsqlファイルを用いて初期化されている。
+	db := open(t)
+	defer db.Close()
+	ctx := context.Background()
+
+	tests := []struct {
+		name      string
+		opts      []Option
+		query     string
+		want      Person
+		wantError error
+	}{
+		{
+			name:  "one row",
+			query: `SELECT first_name, last_name, email FROM persons WHERE employee_no = /*EmpNo*/1`,
+			want: Person{
+				FirstName: "Malvina",
+				LastName:  "FitzSimons",
+				Email:     "malvinafitzsimons@example.com",
+			},
+		},
+		{
+			name:      "no rows",
+			query:     `SELECT first_name, last_name, email FROM persons WHERE employee_no = /*maxEmpNo*/1`,
+			wantError: sql.ErrNoRows,
+		},
+		{
+			name:  "many rows",
+			query: `SELECT first_name, last_name, email FROM persons ORDER BY employee_no`,
+			want: Person{
+				FirstName: "Evan",
+				LastName:  "MacMans",
+				Email:     "evanmacmans@example.com",
+			},
+		},
+		{
+			name:      "many rows in strict mode",
+			opts:      []Option{WithStrictGet()},
+			query:     `SELECT first_name, last_name, email FROM persons ORDER BY employee_no`,
+			wantError: ErrTooManyRows,
+		},
+		{
+			name:  "one row in strict mode",
+			opts:  []Option{WithStrictGet()},
+			query: `SELECT first_name, last_name, email FROM persons WHERE employee_no = /*EmpNo*/1`,
+			want: Person{
+				FirstName: "Malvina",
+				LastName:  "FitzSimons",
+				Email:     "malvinafitzsimons@example.com",
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tw := New(db, tt.opts...)
+			var person Person
+			err := tw.Get(ctx, &person, tt.query, &Info{EmpNo: 2, MaxEmpNo: 100})
+			if !errors.Is(err, tt.wantError) {
+				t.Fatalf("\nexpected:\n%v\nbut got\n%v\n", tt.wantError, err)
+			}
+			if person != tt.want {
+				t.Errorf("\nexpected:\n%v\nbut got\n%v\n", tt.want, person)
+			}
+		})
+	}
+
+	// 構造体以外にも読み込める
+	var count int
+	if err := New(db, WithStrictGet()).Get(ctx, &count, `SELECT count(*) FROM persons WHERE dept_no < /*deptNo*/1`, &Info{DeptNo: 12}); err != nil {
+		t.Fatalf("get: failed: %v", err)
+	}
+	if count != 2 {
+		t.Errorf("\nexpected:\n%v\nbut got\n%v\n", 2, count)
+	}
+}
+
+func TestQuery(t *testing.T) {
+	//このテストはinit.sqlに依存しています。
+	//データベースは/postgres/init以下のsqlファイルを用いて初期化されている。
+	db := open(t)
+	defer db.Close()
+	tw := New(db)
+	ctx := context.Background()
+
+	rows, err := tw.Query(ctx, `SELECT first_name, last_name, email FROM persons WHERE employee_no < /*maxEmpNo*/1000 ORDER BY employee_no`, &Info{MaxEmpNo: 3})
+	if err != nil {
+		t.Fatalf("query: failed: %v", err)
+	}
+	defer rows.Close()
+
+	var people []Person
+	for rows.Next() {
+		var p Person
+		if err := rows.StructScan(&p); err != nil {
+			t.Fatalf("scan: failed: %v", err)
+		}
+		people = append(people, p)
+	}
+	if err := rows.Err(); err != nil {
+		t.Fatalf("query: failed: %v", err)
+	}
+
+	expected := []Person{
+		{
+			FirstName: "Evan",
+			LastName:  "MacMans",
+			Email:     "evanmacmans@example.com",
+		},
+		{
+			FirstName: "Malvina",
+			LastName:  "FitzSimons",
+			Email:     "malvinafitzsimons@example.com",
+		},
+	}
+	if !match(people, expected) {
+		t.Errorf("\nexpected:\n%v\nbut got\n%v\n", expected, people)
+	}
+}
+
+func TestIter(t *testing.T) {
+	//このテストはinit.sqlに依存しています。
+	//データベースは/postgres/init以下のsqlファイルを用いて初期化されている。
+	db := open(t)
+	defer db.Close()
+	tw := New(db)
+	ctx := context.Background()
+
+	rows, err := tw.Query(ctx, `SELECT first_name, last_name, email FROM persons ORDER BY employee_no`, nil)
+	if err != nil {
+		t.Fatalf("query: failed: %v", err)
+	}
+	var people []Person
+	for p, err := range Iter[Person](rows) {
+		if err != nil {
+			t.Fatalf("iter: failed: %v", err)
+		}
+		people = append(people, p)
+		// 途中で抜けても閉じられる
+		if len(people) == 2 {
+			break
+		}
+	}
+	if len(people) != 2 || people[1].FirstName != "Malvina" {
+		t.Errorf("unexpected result: %v", people)
+	}
+	if rows.Next() {
+		t.Error("rows should be closed")
+	}
+
+	// スカラー値も読み込める
+	rows, err = tw.Query(ctx, `SELECT first_name FROM persons WHERE dept_no = /*deptNo*/1`, &Info{DeptNo: 12})
+	if err != nil {
+		t.Fatalf("query: failed: %v", err)
+	}
+	var names []string
+	for name, err := range Iter[string](rows) {
+		if err != nil {
+			t.Fatalf("iter: failed: %v", err)
+		}
+		names = append(names, name)
+	}
+	if len(names) != 1 || names[0] != "Jimmie" {
+		t.Errorf("unexpected result: %v", names)
+	}
+
+	// 読み込めない場合はエラーを返して止まる
+	rows, err = tw.Query(ctx, `SELECT first_name, last_name FROM persons`, nil)
+	if err != nil {
+		t.Fatalf("query: failed: %v", err)
+	}
+	count := 0
+	for _, err := range Iter[int](rows) {
+		count++
+		if err == nil {
+			t.Error("should return error")
+		}
+	}
+	if count != 1 {
+		t.Errorf("iterator should stop after an error: %d", count)
+	}
+}
+
+func TestGenericHelpers(t *testing.T) {
+	//このテストはinit.sqlに依存しています。
+	//データベースは/postgres/init以下のsqlファイルを用いて初期化されている。
+	db := open(t)
+	defer db.Close()
+	tw := New(db)
+	ctx := context.Background()
+
+	people, err := SelectAs[Person](ctx, tw, `SELECT first_name, last_name, email FROM persons WHERE employee_no < /*maxEmpNo*/1000 ORDER BY employee_no`, &Info{MaxEmpNo: 2})
+	if err != nil {
+		t.Fatalf("select: failed: %v", err)
+	}
+	expected := []Person{
+		{
+			FirstName: "Evan",
+			LastName:  "MacMans",
+			Email:     "evanmacmans@example.com",
+		},
+	}
+	if !match(people, expected) {
+		t.Errorf("\nexpected:\n%v\nbut got\n%v\n", expected, people)
+	}
+
+	tx, err := tw.Begin(ctx)
+	if err != nil {
+		t.Fatalf("begin: failed: %v", err)
+	}
+	defer tx.Rollback()
+
+	person, err := GetAs[Person](ctx, tx, `SELECT first_name, last_name, email FROM persons WHERE employee_no = /*EmpNo*/1`, &Info{EmpNo: 1})
+	if err != nil {
+		t.Fatalf("get: failed: %v", err)
+	}
+	if person != expected[0] {
+		t.Errorf("\nexpected:\n%v\nbut got\n%v\n", expected[0], person)
+	}
+
+	var names []string
+	for name, err := range QueryIter[string](ctx, tx, `SELECT first_name FROM persons ORDER BY employee_no`, nil) {
+		if err != nil {
+			t.Fatalf("iter: failed: %v", err)
+		}
+		names = append(names, name)
+	}
+	if len(names) != 3 {
+		t.Errorf("unexpected result: %v", names)
+	}
+}
+
+func TestQuerier(t *testing.T) {
+	//このテストはinit.sqlに依存しています。
+	//データベースは/postgres/init以下のsqlファイルを用いて初期化されている。
+	db := open(t)
+	defer db.Close()
+	tw := New(db)
+	ctx := context.Background()
+
+	// トランザクションの有無にかかわらず同じ関数を使える
+	rename := func(q Querier, name string) error {
+		_, err := q.Exec(ctx, `UPDATE persons SET first_name = /*firstName*/'Jon' WHERE employee_no = /*EmpNo*/1`, &Info{EmpNo: 3, FirstName: name})
+		return err
+	}
+	firstName := func(q Querier) string {
+		var name string
+		if err := q.Get(ctx, &name, `SELECT first_name FROM persons WHERE employee_no = /*EmpNo*/1`, &Info{EmpNo: 3}); err != nil {
+			t.Fatalf("get: failed: %v", err)
+		}
+		return name
+	}
+
+	err := tw.Transaction(ctx, func(tx *TwowaysqlTx) error {
+		if err := rename(tx, "Renamed"); err != nil {
+			return err
+		}
+		if name := firstName(tx); name != "Renamed" {
+			t.Errorf("\nexpected:\n%v\nbut got\n%v\n", "Renamed", name)
+		}
+		return errors.New("rollback")
+	})
+	if err == nil || err.Error() != "rollback" {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if name := firstName(tw); name != "Jimmie" {
+		t.Errorf("\nexpected:\n%v\nbut got\n%v\n", "Jimmie", name)
+	}
+}
+
+func TestTxOptions(t *testing.T) {
+	//このテストはinit.sqlに依存しています。
+	//データベースは/postgres/init以下のsqlファイルを用いて初期化されている。
+	db := open(t)
+	defer db.Close()
+	tw := New(db)
+	ctx := context.Background()
+
+	err := tw.TransactionWithOptions(ctx, &sql.TxOptions{Isolation: sql.LevelSerializable, ReadOnly: true}, func(tx *TwowaysqlTx) error {
+		if !tx.ReadOnly() {
+			t.Error("transaction should be read-only")
+		}
+		if level := tx.TxOptions().Isolation; level != sql.LevelSerializable {
+			t.Errorf("\nexpected:\n%v\nbut got\n%v\n", sql.LevelSerializable, level)
+		}
+
+		var isolation string
+		if err := tx.Get(ctx, &isolation, `SHOW transaction_isolation`, nil); err != nil {
+			return err
+		}
+		if isolation != "serializable" {
+			t.Errorf("\nexpected:\n%v\nbut got\n%v\n", "serializable", isolation)
+		}
+
+		// 読み取り専用なので更新できない
+		if _, err := tx.Exec(ctx, `UPDATE persons SET dept_no = /*deptNo*/1 WHERE employee_no = /*EmpNo*/1`, &Info{EmpNo: 1, DeptNo: 99}); err == nil {
+			t.Error("update should fail in a read-only transaction")
+		}
+		return nil
+	})
+	if err == nil {
+		// 失敗したUPDATEでトランザクションが中断されているのでコミットできない
+		t.Error("commit should fail after an error")
+	}
+
+	tx, err := tw.BeginTx(ctx, nil)
+	if err != nil {
+		t.Fatalf("begin: failed: %v", err)
+	}
+	defer tx.Rollback()
+	if tx.ReadOnly() || tx.TxOptions() != (sql.TxOptions{}) {
+		t.Errorf("unexpected options: %v", tx.TxOptions())
+	}
+}
+
+func TestNestedTransaction(t *testing.T) {
+	//このテストはinit.sqlに依存しています。
+	//データベースは/postgres/init以下のsqlファイルを用いて初期化されている。
+	db := open(t)
+	defer db.Close()
+	tw := New(db)
+	ctx := context.Background()
+
+	const update = `UPDATE persons SET first_name = /*firstName*/'Jon' WHERE employee_no = /*EmpNo*/1`
+	firstName := func(q Querier, empNo int) string {
+		var name string
+		if err := q.Get(ctx, &name, `SELECT first_name FROM persons WHERE employee_no = /*EmpNo*/1`, &Info{EmpNo: empNo}); err != nil {
+			t.Fatalf("get: failed: %v", err)
+		}
+		return name
+	}
+
+	tx, err := tw.Begin(ctx)
+	if err != nil {
+		t.Fatalf("begin: failed: %v", err)
+	}
+	// 外側のトランザクションは最後にロールバックしてデータを元に戻す
+	defer tx.Rollback()
+
+	if _, err := tx.Exec(ctx, update, &Info{EmpNo: 1, FirstName: "Outer"}); err != nil {
+		t.Fatalf("exec: failed: %v", err)
+	}
+
+	// 内側の失敗は内側の変更だけを取り消す
+	innerErr := errors.New("inner error")
+	err = tx.Transaction(ctx, func(inner *TwowaysqlTx) error {
+		if _, err := inner.Exec(ctx, update, &Info{EmpNo: 2, FirstName: "Inner"}); err != nil {
+			return err
+		}
+		if err := inner.Commit(); !errors.Is(err, ErrNestedTx) {
+			t.Errorf("\nexpected:\n%v\nbut got\n%v\n", ErrNestedTx, err)
+		}
+		return innerErr
+	})
+	if !errors.Is(err, innerErr) {
+		t.Fatalf("\nexpected:\n%v\nbut got\n%v\n", innerErr, err)
+	}
+	if name := firstName(tx, 2); name != "Malvina" {
+		t.Errorf("\nexpected:\n%v\nbut got\n%v\n", "Malvina", name)
+	}
+
+	// 成功した内側の変更と、さらに入れ子になったトランザクションの変更は残る
+	err = tx.Transaction(ctx, func(inner *TwowaysqlTx) error {
+		if _, err := inner.Exec(ctx, update, &Info{EmpNo: 3, FirstName: "Inner"}); err != nil {
+			return err
+		}
+		return inner.Transaction(ctx, func(innermost *TwowaysqlTx) error {
+			_, err := innermost.Exec(ctx, update, &Info{EmpNo: 2, FirstName: "Innermost"})
+			return err
+		})
+	})
+	if err != nil {
+		t.Fatalf("nested transaction: failed: %v", err)
+	}
+	for empNo, want := range map[int]string{1: "Outer", 2: "Innermost", 3: "Inner"} {
+		if name := firstName(tx, empNo); name != want {
+			t.Errorf("\nexpected:\n%v\nbut got\n%v\n", want, name)
+		}
+	}
+}
+
+func TestTransactionRetry(t *testing.T) {
+	//このテストはinit.sqlに依存しています。
+	//データベースは/postgres/init以下のsqlファイルを用いて初期化されている。
+	db := open(t)
+	defer db.Close()
+	tw := New(db, WithRetryPolicy(RetryPolicy{MaxAttempts: 3}))
+	ctx := context.Background()
+	defer tw.Exec(ctx, `UPDATE persons SET first_name = 'Jimmie' WHERE employee_no = 3`, nil)
+
+	attempts := 0
+	err := tw.Transaction(ctx, func(tx *TwowaysqlTx) error {
+		attempts++
+		if _, err := tx.Exec(ctx, `UPDATE persons SET first_name = /*firstName*/'Jon' WHERE employee_no = /*EmpNo*/1`, &Info{EmpNo: 3, FirstName: fmt.Sprintf("Attempt%d", attempts)}); err != nil {
+			return err
+		}
+		if attempts == 1 {
+			// シリアライズ失敗を装う
+			return &sqlStateError{code: "40001"}
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("transaction: failed: %v", err)
+	}
+	if attempts != 2 {
+		t.Errorf("\nexpected:\n%v\nbut got\n%v\n", 2, attempts)
+	}
+
+	var name string
+	if err := tw.Get(ctx, &name, `SELECT first_name FROM persons WHERE employee_no = 3`, nil); err != nil {
+		t.Fatalf("get: failed: %v", err)
+	}
+	if name != "Attempt2" {
+		t.Errorf("\nexpected:\n%v\nbut got\n%v\n", "Attempt2", name)
+	}
+}
+
+func TestTransactionPanic(t *testing.T) {
+	//このテストはinit.sqlに依存しています。
+	//データベースは/postgres/init以下のsqlファイルを用いて初期化されている。
+	db := open(t)
+	defer db.Close()
+	ctx := context.Background()
+
+	const update = `UPDATE persons SET first_name = /*firstName*/'Jon' WHERE employee_no = /*EmpNo*/1`
+	check := func() {
+		var name string
+		if err := New(db).Get(ctx, &name, `SELECT first_name FROM persons WHERE employee_no = 3`, nil); err != nil {
+			t.Fatalf("get: failed: %v", err)
+		}
+		if name != "Jimmie" {
+			t.Errorf("\nexpected:\n%v\nbut got\n%v\n", "Jimmie", name)
+		}
+	}
+
+	// パニックはロールバックしてから再び起こす
+	func() {
+		defer func() {
+			if p := recover(); p != "boom" {
+				t.Errorf("\nexpected:\n%v\nbut got\n%v\n", "boom", p)
+			}
+		}()
+		New(db).Transaction(ctx, func(tx *TwowaysqlTx) error {
+			if _, err := tx.Exec(ctx, update, &Info{EmpNo: 3, FirstName: "Panic"}); err != nil {
+				return err
+			}
+			panic("boom")
+		})
+	}()
+	check()
+
+	// エラーに変換する
+	err := New(db, WithPanicAsError()).Transaction(ctx, func(tx *TwowaysqlTx) error {
+		if _, err := tx.Exec(ctx, update, &Info{EmpNo: 3, FirstName: "Panic"}); err != nil {
+			return err
+		}
+		panic("boom")
+	})
+	var perr *PanicError
+	if !errors.As(err, &perr) || perr.Value != "boom" {
+		t.Errorf("should return *PanicError: %v", err)
+	}
+	check()
+}
+
+func TestFile(t *testing.T) {
+	//このテストはinit.sqlに依存しています。
+	//データベースは/postgres/init以下のsqlファイルを用いて初期化されている。
+	db := open(t)
+	defer db.Close()
+	sqlFS, err := fs.Sub(testSQL, "testdata/sql")
+	if err != nil {
+		t.Fatal(err)
+	}
+	tw := New(db, WithLoader(NewLoader(sqlFS)))
+	ctx := context.Background()
+
+	var people []Person
+	if err := tw.SelectFile(ctx, &people, "persons/find.sql", &Info{MaxEmpNo: 3, DeptNo: 12}); err != nil {
+		t.Fatalf("select: failed: %v", err)
+	}
+	expected := []Person{
+		{
+			FirstName: "Evan",
+			LastName:  "MacMans",
+			Email:     "evanmacmans@example.com",
+		},
+		{
+			FirstName: "Malvina",
+			LastName:  "FitzSimons",
+			Email:     "malvinafitzsimons@example.com",
+		},
+	}
+	if !match(people, expected) {
+		t.Errorf("\nexpected:\n%v\nbut got\n%v\n", expected, people)
+	}
+
+	err = tw.Transaction(ctx, func(tx *TwowaysqlTx) error {
+		if _, err := tx.ExecFile(ctx, "persons/update_name.sql", &Info{EmpNo: 1, FirstName: "File"}); err != nil {
+			return err
+		}
+		var person Person
+		if err := tx.GetFile(ctx, &person, "persons/find.sql", &Info{MaxEmpNo: 2}); err != nil {
+			return err
+		}
+		if person.FirstName != "File" {
+			t.Errorf("\nexpected:\n%v\nbut got\n%v\n", "File", person.FirstName)
+		}
+		return errors.New("rollback")
+	})
+	if err == nil || err.Error() != "rollback" {
+		t.Errorf("unexpected error: %v", err)
+	}
+}
+
+func TestNamedQuery(t *testing.T) {
+	//このテストはinit.sqlに依存しています。
+	//データベースは/postgres/init以下のsqlファイルを用いて初期化されている。
+	db := open(t)
+	defer db.Close()
+	registry := NewRegistry()
+	if err := registry.AddFS(testSQL, "testdata/sql/named/*.sql"); err != nil {
+		t.Fatal(err)
+	}
+	tw := New(db, WithRegistry(registry))
+	ctx := context.Background()
+
+	var people []Person
+	if err := tw.SelectNamed(ctx, &people, "FindPersonsByDept", &Info{MaxEmpNo: 3, DeptNo: 12}); err != nil {
+		t.Fatalf("select: failed: %v", err)
+	}
+	expected := []Person{
+		{
+			FirstName: "Evan",
+			LastName:  "MacMans",
+			Email:     "evanmacmans@example.com",
+		},
+		{
+			FirstName: "Malvina",
+			LastName:  "FitzSimons",
+			Email:     "malvinafitzsimons@example.com",
+		},
+	}
+	if !match(people, expected) {
+		t.Errorf("\nexpected:\n%v\nbut got\n%v\n", expected, people)
+	}
+
+	err := tw.Transaction(ctx, func(tx *TwowaysqlTx) error {
+		if _, err := tx.ExecNamed(ctx, "UpdatePersonName", &Info{EmpNo: 1, FirstName: "Named"}); err != nil {
+			return err
+		}
+		var person Person
+		if err := tx.GetNamed(ctx, &person, "FindPersonsByDept", &Info{MaxEmpNo: 2}); err != nil {
+			return err
+		}
+		if person.FirstName != "Named" {
+			t.Errorf("\nexpected:\n%v\nbut got\n%v\n", "Named", person.FirstName)
+		}
+		return errors.New("rollback")
+	})
+	if err == nil || err.Error() != "rollback" {
+		t.Errorf("unexpected error: %v", err)
+	}
+
+	if err := tw.SelectNamed(ctx, &people, "FindPersons", nil); !errors.Is(err, ErrQueryNotFound) {
+		t.Errorf("should return ErrQueryNotFound: %v", err)
+	}
+}
+
 func TestUpdate(t *testing.T) {
 	//このテストはinit.sqlに依存しています。
 	//データベースは/postgres/init以下のsqlファイルを用いて初期化されている。
@@ -316,7 +933,7 @@ func TestTxBlock(t *testing.T) {
 		FirstName string `twowaysql:"firstName"`
 	}
 	// commit case
-	err := tw.Transaction(ctx, func(tx TwowaysqlTx) error {
+	err := tw.Transaction(ctx, func(tx *TwowaysqlTx) error {
 		// update
 		const sql = `
 		UPDATE
@@ -343,7 +960,7 @@ func TestTxBlock(t *testing.T) {
 	}
 
 	// rollcack case
-	err = tw.Transaction(ctx, func(tx TwowaysqlTx) error {
+	err = tw.Transaction(ctx, func(tx *TwowaysqlTx) error {
 		// update
 		const sql = `
 		UPDATE
@@ -0,0 +1,61 @@
+package twowaysql
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+)
+
+var (
+	// /*$name*/に埋め込める識別子。schema.table.columnのような修飾も許す
+	identifierPattern = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_$]*(\.[A-Za-z_][A-Za-z0-9_$]*)*$`)
+	// 許可リストがない場合に/*#name*/に埋め込める値。並び順のキーワードまたは整数
+	// OR, UNIONなどの任意のキーワードを許すと文の意味を変えられるので、許可リストなしでは受け付けない
+	keywordPattern = regexp.MustCompile(`(?i)^((ASC|DESC)( NULLS (FIRST|LAST))?|NULLS (FIRST|LAST)|-?[0-9]+)$`)
+)
+
+// embedValueは/*$name*/, /*#name*/に埋め込む文字列を検証して返す
+// /*$name*/は列名などの識別子、/*#name*/はASC, DESC, NULLS FIRST, NULLS LASTか整数だけを受け付ける
+// WithAllowedValuesで許可リストが指定されている場合は、そのいずれかと一致しなければならない
+func embedValue(tok token, value interface{}, o *options) (string, error) {
+	sigil, name := tok.value[:1], tok.value[1:]
+
+	var str string
+	switch v := normalize(value).(type) {
+	case string:
+		str = v
+	case int64:
+		if sigil == "#" {
+			str = strconv.FormatInt(v, 10)
+			break
+		}
+		return "", fmt.Errorf("embedded value %s must be a string, but got %T", tok.value, value)
+	default:
+		return "", fmt.Errorf("embedded value %s must be a string, but got %T", tok.value, value)
+	}
+
+	if allowed, ok := o.allowed[name]; ok {
+		for _, a := range allowed {
+			if str == a {
+				return str, nil
+			}
+		}
+		return "", fmt.Errorf("embedded value %s is not allowed: %q", tok.value, str)
+	}
+
+	pattern := keywordPattern
+	if sigil == "$" {
+		pattern = identifierPattern
+	}
+	if !pattern.MatchString(str) {
+		return "", fmt.Errorf("embedded value %s is not a valid %s: %q", tok.value, embedKind(sigil), str)
+	}
+	return str, nil
+}
+
+func embedKind(sigil string) string {
+	if sigil == "$" {
+		return "identifier"
+	}
+	return "keyword"
+}
@@ -0,0 +1,172 @@
+package twowaysql
+
+import (
+	"testing"
+)
+
+type SortInfo struct {
+	DeptNo    int      `twowaysql:"deptNo"`
+	Column    string   `twowaysql:"sortColumn"`
+	Direction string   `twowaysql:"direction"`
+	Limit     int      `twowaysql:"limit"`
+	Table     string   `twowaysql:"table"`
+	Columns   []string `twowaysql:"columns"`
+}
+
+func TestEvalEmbed(t *testing.T) {
+	tests := []struct {
+		name        string
+		input       string
+		inputParams SortInfo
+		options     []Option
+		wantQuery   string
+		wantParams  []interface{}
+	}{
+		{
+			name:        "identifier and keyword",
+			input:       `SELECT * FROM persons WHERE dept_no = /*deptNo*/1 ORDER BY /*$sortColumn*/employee_no /*#direction*/ASC`,
+			inputParams: SortInfo{DeptNo: 3, Column: "first_name", Direction: "DESC"},
+			wantQuery:   `SELECT * FROM persons WHERE dept_no = ?/*deptNo*/ ORDER BY first_name/*$sortColumn*/ DESC/*#direction*/`,
+			wantParams:  []interface{}{3},
+		},
+		{
+			name:        "qualified identifier",
+			input:       `SELECT * FROM /*$table*/persons`,
+			inputParams: SortInfo{Table: "public.persons"},
+			wantQuery:   `SELECT * FROM public.persons/*$table*/`,
+			wantParams:  []interface{}{},
+		},
+		{
+			name:        "integer",
+			input:       `SELECT * FROM persons LIMIT /*#limit*/10`,
+			inputParams: SortInfo{Limit: 20},
+			wantQuery:   `SELECT * FROM persons LIMIT 20/*#limit*/`,
+			wantParams:  []interface{}{},
+		},
+		{
+			name:        "nulls last",
+			input:       `SELECT * FROM persons ORDER BY employee_no /*#direction*/ASC`,
+			inputParams: SortInfo{Direction: "desc nulls last"},
+			wantQuery:   `SELECT * FROM persons ORDER BY employee_no desc nulls last/*#direction*/`,
+			wantParams:  []interface{}{},
+		},
+		{
+			name:        "allowed keyword",
+			input:       `SELECT /*#direction*/ALL first_name FROM persons`,
+			inputParams: SortInfo{Direction: "DISTINCT"},
+			options:     []Option{WithAllowedValues("direction", "ALL", "DISTINCT")},
+			wantQuery:   `SELECT DISTINCT/*#direction*/ first_name FROM persons`,
+			wantParams:  []interface{}{},
+		},
+		{
+			name:        "allowlist",
+			input:       `SELECT * FROM persons ORDER BY /*$sortColumn*/employee_no /*#direction*/ASC`,
+			inputParams: SortInfo{Column: "age", Direction: "DESC NULLS LAST"},
+			options:     []Option{WithAllowedValues("direction", "ASC", "DESC", "DESC NULLS LAST")},
+			wantQuery:   `SELECT * FROM persons ORDER BY age/*$sortColumn*/ DESC NULLS LAST/*#direction*/`,
+			wantParams:  []interface{}{},
+		},
+		{
+			name:        "in condition",
+			input:       `SELECT * FROM persons /* IF sortColumn */ORDER BY /*$sortColumn*/employee_no /* END */`,
+			inputParams: SortInfo{},
+			wantQuery:   `SELECT * FROM persons`,
+			wantParams:  []interface{}{},
+		},
+		{
+			name:        "in loop",
+			input:       `SELECT /* FOR c IN columns SEPARATOR ',' */ /*$c*/first_name /* END */ FROM persons`,
+			inputParams: SortInfo{Columns: []string{"first_name", "last_name"}},
+			wantQuery:   `SELECT first_name/*$c*/ , last_name/*$c*/ FROM persons`,
+			wantParams:  []interface{}{},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if query, params, err := Eval(tt.input, &tt.inputParams, tt.options...); err != nil || query != tt.wantQuery || !interfaceSliceEqual(params, tt.wantParams) {
+				if err != nil {
+					t.Error(err)
+				}
+				if query != tt.wantQuery {
+					t.Errorf("Doesn't Match\nexpected: \n%s\n but got: \n%s\n", tt.wantQuery, query)
+				}
+				if !interfaceSliceEqual(params, tt.wantParams) {
+					t.Errorf("Doesn't Match\nexpected: \n%v\n but got: \n%v\n", tt.wantParams, params)
+				}
+			}
+		})
+	}
+}
+
+func TestEvalEmbedAbnormal(t *testing.T) {
+	tests := []struct {
+		name        string
+		input       string
+		inputParams SortInfo
+		options     []Option
+		wantError   string
+	}{
+		{
+			name:        "no parameter",
+			input:       `SELECT * FROM persons ORDER BY /*$sortKey*/employee_no`,
+			inputParams: SortInfo{},
+			wantError:   "no parameter that matches the embedded value: $sortKey",
+		},
+		{
+			name:        "injection in identifier",
+			input:       `SELECT * FROM persons ORDER BY /*$sortColumn*/employee_no`,
+			inputParams: SortInfo{Column: "age; DROP TABLE persons"},
+			wantError:   `embedded value $sortColumn is not a valid identifier: "age; DROP TABLE persons"`,
+		},
+		{
+			name:        "empty identifier",
+			input:       `SELECT * FROM persons ORDER BY /*$sortColumn*/employee_no`,
+			inputParams: SortInfo{},
+			wantError:   `embedded value $sortColumn is not a valid identifier: ""`,
+		},
+		{
+			name:        "injection in keyword",
+			input:       `SELECT * FROM persons ORDER BY employee_no /*#direction*/ASC`,
+			inputParams: SortInfo{Direction: "ASC, (SELECT 1)"},
+			wantError:   `embedded value #direction is not a valid keyword: "ASC, (SELECT 1)"`,
+		},
+		{
+			name:        "other keyword",
+			input:       `SELECT * FROM persons WHERE dept_no = 1 /*#direction*/AND employee_no = 2`,
+			inputParams: SortInfo{Direction: "OR"},
+			wantError:   `embedded value #direction is not a valid keyword: "OR"`,
+		},
+		{
+			name:        "not in allowlist",
+			input:       `SELECT * FROM persons ORDER BY /*$sortColumn*/employee_no`,
+			inputParams: SortInfo{Column: "password"},
+			options:     []Option{WithAllowedValues("sortColumn", "employee_no", "first_name")},
+			wantError:   `embedded value $sortColumn is not allowed: "password"`,
+		},
+		{
+			name:        "integer identifier",
+			input:       `SELECT * FROM persons ORDER BY /*$deptNo*/employee_no`,
+			inputParams: SortInfo{DeptNo: 1},
+			wantError:   "embedded value $deptNo must be a string, but got int",
+		},
+		{
+			name:        "slice",
+			input:       `SELECT * FROM persons ORDER BY /*#columns*/employee_no`,
+			inputParams: SortInfo{Columns: []string{"age"}},
+			wantError:   "embedded value #columns must be a string, but got []string",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, _, err := Eval(tt.input, &tt.inputParams, tt.options...); err == nil || err.Error() != tt.wantError {
+				if err == nil {
+					t.Errorf("should return error")
+				} else {
+					t.Errorf("\nexpected:\n%v\nbut got\n%v\n", tt.wantError, err.Error())
+				}
+			}
+		})
+	}
+}
@@ -2,108 +2,204 @@ package twowaysql
 
 import (
 	"bytes"
+	"database/sql"
+	"database/sql/driver"
 	"fmt"
 	"reflect"
 	"strings"
-	"unicode"
 
 	"gitlab.com/osaki-lab/tagscanner/runtimescan"
 )
 
 // Eval returns converted query and bind value.
-// inputParams takes a tagged struct. Tags must be in the form `map:"tag_name"`.
-// The return value is expected to be used to issue queries to the database
-func Eval(inputQuery string, inputParams interface{}) (string, []interface{}, error) {
-	mapParams := map[string]interface{}{}
-
-	query := formatQuery(inputQuery)
-
-	if inputParams != nil {
-		if err := encode(mapParams, inputParams); err != nil {
-			return "", nil, err
-		}
-	} else {
-		mapParams = nil
-	}
-
-	tokens, err := tokenize(query)
+// inputParams takes a tagged struct or a map[string]interface{}. Tags must be in the form `twowaysql:"tag_name"`.
+// The return value is expected to be used to issue queries to the database.
+// When the same query is evaluated repeatedly, use Compile and Template.Eval to parse it only once.
+func Eval(inputQuery string, inputParams interface{}, opts ...Option) (string, []interface{}, error) {
+	tmpl, err := Compile(inputQuery, opts...)
 	if err != nil {
 		return "", nil, err
 	}
 
-	tree, err := ast(tokens)
-	if err != nil {
-		return "", nil, err
-	}
-
-	generatedTokens, err := tree.parse(mapParams)
-	if err != nil {
-		return "", nil, err
-	}
+	return tmpl.Eval(inputParams)
+}
 
-	convertedQuery, params, err := build(generatedTokens, mapParams)
+// EvalNamed is like Eval, but writes :name placeholders and returns the bind values as sql.NamedArg.
+// The names are those of the bind variables. Names that appear more than once, e.g. in FOR or IN lists,
+// get suffixes such as name_2. Dots in the names are replaced with underscores,
+// and names not starting with a letter get the prefix p, as sql.Named requires.
+// Generated names skip the names of the other bind variables in the query.
+func EvalNamed(inputQuery string, inputParams interface{}, opts ...Option) (string, []sql.NamedArg, error) {
+	tmpl, err := Compile(inputQuery, opts...)
 	if err != nil {
 		return "", nil, err
 	}
 
-	return arrangeWhiteSpace(convertedQuery), params, nil
+	return tmpl.EvalNamed(inputParams)
 }
 
-func build(tokens []token, inputParams map[string]interface{}) (string, []interface{}, error) {
+func build(tokens []token, inputParams map[string]interface{}, o *options, style Placeholder) (string, []interface{}, error) {
 	var b strings.Builder
-	params := make([]interface{}, 0, len(tokens))
+	bd := newBinder(style, len(tokens), o.placeholderFunc)
+	bd.reserve(tokens)
 
 	for _, token := range tokens {
+		if token.kind == tkEmbed {
+			elem, err := bindValue(token, inputParams)
+			if err != nil {
+				return "", nil, fmt.Errorf("no parameter that matches the embedded value: %s", describeMissing(token.value, err))
+			}
+			str, err := embedValue(token, elem, o)
+			if err != nil {
+				return "", nil, err
+			}
+			// バインド変数と同じく元のコメントを残す
+			b.WriteString(str + token.str)
+			continue
+		}
 		if token.kind == tkBind {
-			if elem, ok := inputParams[token.value]; ok {
-				switch slice := elem.(type) {
-				case []string:
-					token.str = bindLiterals(token.str, len(slice))
-					for _, value := range slice {
-						params = append(params, value)
+			elem, err := bindValue(token, inputParams)
+			if err != nil {
+				return "", nil, fmt.Errorf("no parameter that matches the bind value: %s", describeMissing(token.value, err))
+			}
+			// ?/* ... */の?をプレースホルダに置き換える
+			comment := strings.TrimPrefix(token.str, "?")
+			if values, ok := expandSlice(elem); ok {
+				if len(values) == 0 {
+					if o.emptySlice == EmptySliceError {
+						return "", nil, fmt.Errorf("empty slice for the bind value: %s", token.value)
 					}
-				case []int:
-					token.str = bindLiterals(token.str, len(slice))
-					for _, value := range slice {
-						params = append(params, value)
+					token.str = "(NULL)" + comment
+				} else {
+					// ?/* ... */ -> (?, ?, ?)/* ... */みたいにする
+					placeholders := make([]string, len(values))
+					for i, value := range values {
+						if !bindableElem(value) {
+							return "", nil, fmt.Errorf("can not bind %T in the slice for the bind value: %s", value, token.value)
+						}
+						placeholders[i] = bd.bind(token.value, value)
 					}
-				default:
-					params = append(params, elem)
+					token.str = "(" + strings.Join(placeholders, ", ") + ")" + comment
 				}
 			} else {
-				return "", nil, fmt.Errorf("no parameter that matches the bind value: %s", token.value)
+				token.str = bd.bind(token.value, elem) + comment
 			}
 		}
 		b.WriteString(token.str)
 	}
-	return b.String(), params, nil
+	return b.String(), bd.args, nil
 }
 
-// ?/* ... */ -> (?, ?, ?)/* ... */みたいにする
-func bindLiterals(str string, number int) string {
-	str = strings.TrimLeftFunc(str, func(r rune) bool {
-		return r != unicode.SimpleFold('/')
-	})
-	var b strings.Builder
-	b.WriteRune('(')
-	for i := 0; i < number; i++ {
-		b.WriteRune('?')
-		if i != number-1 {
-			b.WriteString(", ")
-		}
+// bindValueはバインド変数、埋め込み変数の値を取り出す
+// FORの中ではループ変数も含めて探す
+func bindValue(tok token, inputParams map[string]interface{}) (interface{}, error) {
+	name := tok.value
+	if tok.kind == tkEmbed {
+		// 先頭の$, #を取り除く
+		name = name[1:]
+	}
+	if tok.scope != nil {
+		return tok.scope.lookup(name)
+	}
+	if elem, ok := inputParams[name]; ok {
+		return elem, nil
+	}
+	return lookupPath(func(name string) (interface{}, bool) {
+		elem, ok := inputParams[name]
+		return elem, ok
+	}, name)
+}
+
+// expandSliceはIN句に展開するスライス、配列の要素を取り出す
+// []byteやuuid.UUIDのようなバイト列、driver.Valuerを実装する型は一つの値として扱う
+func expandSlice(v interface{}) ([]interface{}, bool) {
+	if v == nil {
+		return nil, false
 	}
-	b.WriteRune(')')
+	if _, ok := v.(driver.Valuer); ok {
+		return nil, false
+	}
+	rv := reflect.ValueOf(v)
+	if rv.Kind() == reflect.Ptr && !rv.IsNil() && (rv.Elem().Kind() == reflect.Slice || rv.Elem().Kind() == reflect.Array) {
+		return expandSlice(rv.Elem().Interface())
+	}
+	if rv.Kind() != reflect.Slice && rv.Kind() != reflect.Array || rv.Type().Elem().Kind() == reflect.Uint8 {
+		return nil, false
+	}
+	values := make([]interface{}, rv.Len())
+	for i := range values {
+		values[i] = rv.Index(i).Interface()
+	}
+	return values, true
+}
 
-	return fmt.Sprint(b.String(), str)
+// bindableElemはIN句の要素として一つの値でバインドできるかを返す
+// マップや構造体はドライバが実行するときまでエラーにならないので、評価するときにエラーにする
+func bindableElem(v interface{}) bool {
+	if v == nil {
+		return true
+	}
+	if _, ok := v.(driver.Valuer); ok {
+		return true
+	}
+	rv := reflect.ValueOf(v)
+	for rv.Kind() == reflect.Ptr && !rv.IsNil() {
+		rv = rv.Elem()
+	}
+	switch rv.Kind() {
+	case reflect.Map:
+		return false
+	case reflect.Struct:
+		return isValueType(rv.Type())
+	}
+	return true
 }
 
 func formatQuery(query string) string {
+	// 改行をなくすと行末までのコメントが後ろの行を飲み込むので先に取り除く
+	query = removeLineComments(query)
+	query = strings.ReplaceAll(query, "\r", " ")
 	query = strings.ReplaceAll(query, "\n", " ")
 	query = strings.ReplaceAll(query, "\t", " ")
 	query = strings.TrimSpace(query)
 	return query
 }
 
+// removeLineCommentsは-- から行末までのコメントを取り除く
+// 文字列リテラルと/* ... */の中の--はそのまま残す
+func removeLineComments(query string) string {
+	if !strings.Contains(query, "--") {
+		return query
+	}
+	var b strings.Builder
+	for i := 0; i < len(query); i++ {
+		switch c := query[i]; {
+		case c == '\'' || c == '"' || c == '`':
+			end := i + quotedLen(query[i:])
+			b.WriteString(query[i:end])
+			i = end - 1
+		case strings.HasPrefix(query[i:], "/*"):
+			end := strings.Index(query[i+2:], "*/")
+			if end < 0 {
+				b.WriteString(query[i:])
+				return b.String()
+			}
+			end += i + 4
+			b.WriteString(query[i:end])
+			i = end - 1
+		case strings.HasPrefix(query[i:], "--"):
+			end := strings.IndexByte(query[i:], '\n')
+			if end < 0 {
+				return b.String()
+			}
+			i += end - 1
+		default:
+			b.WriteByte(c)
+		}
+	}
+	return b.String()
+}
+
 // 空白が二つ以上続いていたら一つにする。=1 -> = 1のような変換はできない
 // 単純な空白を想定。 -> issue: よりロバストな実装
 func arrangeWhiteSpace(str string) string {
@@ -124,11 +220,30 @@ type encoder struct {
 }
 
 func (m encoder) ParseTag(name, tagStr, pathStr string, elemType reflect.Type) (tag interface{}, err error) {
-	return runtimescan.BasicParseTag(name, tagStr, pathStr, elemType)
+	tag, err = runtimescan.BasicParseTag(name, tagStr, pathStr, elemType)
+	if err != nil {
+		return nil, err
+	}
+	// タグのついた構造体はそのまま渡し、name.fieldの形式で参照できるようにする
+	// 子のフィールドはVisitFieldで展開するので、これまでどおり接頭辞なしでも参照できる
+	// time.Timeやsql.NullStringなどは値として扱う
+	// 埋め込みとタグのない構造体はこれまでどおり展開する
+	if elemType.Kind() == reflect.Struct && !strings.HasSuffix(pathStr, "(embed)") && (tagStr != "" || isValueType(elemType)) {
+		return tag, runtimescan.SkipTraverse
+	}
+	return tag, nil
 }
 
 func (m *encoder) VisitField(tag, value interface{}) (err error) {
 	t := tag.(*runtimescan.BasicTag)
+	if rv := reflect.ValueOf(value); rv.Kind() == reflect.Struct && !isValueType(rv.Type()) {
+		// 子のフィールドも展開する。Encodeはポインタを受け取るのでコピーを渡す
+		ptr := reflect.New(rv.Type())
+		ptr.Elem().Set(rv)
+		if err := runtimescan.Encode(ptr.Interface(), "twowaysql", m); err != nil {
+			return err
+		}
+	}
 	m.dest[t.Tag] = value
 	return nil
 }
@@ -142,6 +257,21 @@ func (m encoder) LeaveChild(tag interface{}) (err error) {
 }
 
 func encode(dest map[string]interface{}, src interface{}) error {
+	// マップはタグを見ずにそのままパラメータとして使う
+	rv := reflect.ValueOf(src)
+	for rv.Kind() == reflect.Ptr && !rv.IsNil() && rv.Elem().Kind() == reflect.Map {
+		rv = rv.Elem()
+	}
+	if rv.Kind() == reflect.Map {
+		if rv.Type().Key().Kind() != reflect.String {
+			return fmt.Errorf("map parameter must have string keys, but got %T", src)
+		}
+		iter := rv.MapRange()
+		for iter.Next() {
+			dest[iter.Key().String()] = iter.Value().Interface()
+		}
+		return nil
+	}
 	return runtimescan.Encode(src, "twowaysql", &encoder{
 		dest: dest,
 	})
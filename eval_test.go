@@ -1,7 +1,11 @@
 package twowaysql
 
 import (
+	"bytes"
+	"database/sql"
+	"encoding/json"
 	"testing"
+	"time"
 )
 
 type Info struct {
@@ -77,6 +81,20 @@ func TestEval(t *testing.T) {
 			wantQuery:   `SELECT * FROM person WHERE employee_no < 1000 AND id = 3`,
 			wantParams:  []interface{}{},
 		},
+		{
+			name:        "if true followed by statement",
+			input:       `SELECT employee_no /* IF true */ , dept_no /* END */ FROM person WHERE employee_no < 1000`,
+			inputParams: Info{},
+			wantQuery:   `SELECT employee_no , dept_no FROM person WHERE employee_no < 1000`,
+			wantParams:  []interface{}{},
+		},
+		{
+			name:        "else followed by statement",
+			input:       `SELECT employee_no /* IF false */ , dept_no /* ELSE */ , boss_no /* END */ FROM person WHERE employee_no < 1000`,
+			inputParams: Info{},
+			wantQuery:   `SELECT employee_no , boss_no FROM person WHERE employee_no < 1000`,
+			wantParams:  []interface{}{},
+		},
 		{
 			name:  "bind parameter",
 			input: `SELECT * FROM person WHERE employee_no < /*maxEmpNo*/1000`,
@@ -290,6 +308,24 @@ func TestEval(t *testing.T) {
 			wantQuery:  `SELECT * FROM person WHERE employee_no < 1000 AND id = 3`,
 			wantParams: []interface{}{},
 		},
+		{
+			name: "line comments",
+			input: `
+			-- 社員を検索する
+			SELECT
+				*
+			FROM
+				person -- 社員
+			WHERE
+				employee_no = /*maxEmpNo*/1000 -- 上限
+				AND first_name <> '--'
+			`,
+			inputParams: Info{
+				MaxEmpNo: 3,
+			},
+			wantQuery:  `SELECT * FROM person WHERE employee_no = ?/*maxEmpNo*/ AND first_name <> '--'`,
+			wantParams: []interface{}{3},
+		},
 		{
 			name: "multiline in bind string",
 			input: `
@@ -600,6 +636,362 @@ func TestCondition(t *testing.T) {
 	}
 }
 
+func TestEvalMap(t *testing.T) {
+	type Params map[string]interface{}
+
+	decoded := map[string]interface{}{}
+	if err := json.Unmarshal([]byte(`{
+		"deptNo": 3,
+		"gender_list": ["M", "F"],
+		"filter": {"name": "Tim", "dept": {"no": 5}},
+		"members": [{"name": "Jeff"}, {"name": "Tim"}]
+	}`), &decoded); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name        string
+		input       string
+		inputParams interface{}
+		wantQuery   string
+		wantParams  []interface{}
+	}{
+		{
+			name:        "map",
+			input:       `SELECT * FROM person WHERE employee_no < /*maxEmpNo*/1000 /* IF deptNo */ AND dept_no < /*deptNo*/1 /* END */`,
+			inputParams: map[string]interface{}{"maxEmpNo": 3, "deptNo": 0},
+			wantQuery:   `SELECT * FROM person WHERE employee_no < ?/*maxEmpNo*/`,
+			wantParams:  []interface{}{3},
+		},
+		{
+			name:        "pointer to named map",
+			input:       `SELECT * FROM person WHERE employee_no < /*maxEmpNo*/1000 /* IF deptNo */ AND dept_no < /*deptNo*/1 /* END */`,
+			inputParams: &Params{"maxEmpNo": 3, "deptNo": 5},
+			wantQuery:   `SELECT * FROM person WHERE employee_no < ?/*maxEmpNo*/ AND dept_no < ?/*deptNo*/`,
+			wantParams:  []interface{}{3, 5},
+		},
+		{
+			name:        "decoded json",
+			input:       `SELECT * FROM person WHERE dept_no = /*deptNo*/1 AND gender IN /*gender_list*/('M') /* IF deptNo == 3 */ AND first_name = /*filter.name*/'Jeff' /* END */`,
+			inputParams: decoded,
+			wantQuery:   `SELECT * FROM person WHERE dept_no = ?/*deptNo*/ AND gender IN (?, ?)/*gender_list*/ AND first_name = ?/*filter.name*/`,
+			wantParams:  []interface{}{3.0, "M", "F", "Tim"},
+		},
+		{
+			name:        "nested map",
+			input:       `SELECT * FROM person /* IF filter.dept.no > 1 */ WHERE dept_no = /*filter.dept.no*/1 /* END */`,
+			inputParams: decoded,
+			wantQuery:   `SELECT * FROM person WHERE dept_no = ?/*filter.dept.no*/`,
+			wantParams:  []interface{}{5.0},
+		},
+		{
+			name:        "slice of maps",
+			input:       `SELECT * FROM person WHERE first_name IN (/* FOR m IN members SEPARATOR ',' */ /*m.name*/'Tim' /* END */)`,
+			inputParams: decoded,
+			wantQuery:   `SELECT * FROM person WHERE first_name IN ( ?/*m.name*/ , ?/*m.name*/ )`,
+			wantParams:  []interface{}{"Jeff", "Tim"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if query, params, err := Eval(tt.input, tt.inputParams); err != nil || query != tt.wantQuery || !interfaceSliceEqual(params, tt.wantParams) {
+				if err != nil {
+					t.Error(err)
+				}
+				if query != tt.wantQuery {
+					t.Errorf("Doesn't Match\nexpected: \n%s\n but got: \n%s\n", tt.wantQuery, query)
+				}
+				if !interfaceSliceEqual(params, tt.wantParams) {
+					t.Errorf("Doesn't Match\nexpected: \n%v\n but got: \n%v\n", tt.wantParams, params)
+				}
+			}
+		})
+	}
+
+	if _, _, err := Eval(`SELECT * FROM person WHERE employee_no < /*maxEmpNo*/1000`, map[int]interface{}{1: 1}); err == nil || err.Error() != "map parameter must have string keys, but got map[int]interface {}" {
+		t.Errorf("unexpected error: %v", err)
+	}
+}
+
+type Dept struct {
+	No   int    `twowaysql:"no"`
+	Name string `twowaysql:"name"`
+}
+
+type Filter struct {
+	Dept    *Dept             `twowaysql:"dept"`
+	Since   time.Time         `twowaysql:"since"`
+	Attrs   map[string]string `twowaysql:"attrs"`
+	Keyword sql.NullString    `twowaysql:"keyword"`
+}
+
+type NestedInfo struct {
+	Filter   Filter  `twowaysql:"filter"`
+	Optional *Filter `twowaysql:"optional"`
+}
+
+func TestEvalNested(t *testing.T) {
+	since := time.Date(2020, 4, 1, 0, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		name        string
+		input       string
+		inputParams NestedInfo
+		wantQuery   string
+		wantParams  []interface{}
+	}{
+		{
+			name:        "nested struct",
+			input:       `SELECT * FROM person /* IF filter.dept != null */ WHERE dept_no = /*filter.dept.no*/1 /* END */`,
+			inputParams: NestedInfo{Filter: Filter{Dept: &Dept{No: 10}}},
+			wantQuery:   `SELECT * FROM person WHERE dept_no = ?/*filter.dept.no*/`,
+			wantParams:  []interface{}{10},
+		},
+		{
+			name:        "nil pointer",
+			input:       `SELECT * FROM person /* IF filter.dept != null */ WHERE dept_no = /*filter.dept.no*/1 /* END */`,
+			inputParams: NestedInfo{},
+			wantQuery:   `SELECT * FROM person`,
+			wantParams:  []interface{}{},
+		},
+		{
+			name:        "pointer and map",
+			input:       `SELECT * FROM person WHERE gender = /*optional.attrs.gender*/'M'`,
+			inputParams: NestedInfo{Optional: &Filter{Attrs: map[string]string{"gender": "F"}}},
+			wantQuery:   `SELECT * FROM person WHERE gender = ?/*optional.attrs.gender*/`,
+			wantParams:  []interface{}{"F"},
+		},
+		{
+			name:        "value struct",
+			input:       `SELECT * FROM person WHERE created_at >= /*filter.since*/'2000-01-01' /* IF filter.keyword != null */ AND first_name = /*filter.keyword*/'Tim' /* END */`,
+			inputParams: NestedInfo{Filter: Filter{Since: since}},
+			wantQuery:   `SELECT * FROM person WHERE created_at >= ?/*filter.since*/`,
+			wantParams:  []interface{}{since},
+		},
+		{
+			name:        "fields of tagged struct without prefix",
+			input:       `SELECT * FROM person WHERE created_at >= /*since*/'2000-01-01' AND dept_no = /*dept.no*/1 AND emp_no < /*filter.dept.no*/1`,
+			inputParams: NestedInfo{Filter: Filter{Dept: &Dept{No: 10}, Since: since}},
+			wantQuery:   `SELECT * FROM person WHERE created_at >= ?/*since*/ AND dept_no = ?/*dept.no*/ AND emp_no < ?/*filter.dept.no*/`,
+			wantParams:  []interface{}{since, 10, 10},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if query, params, err := Eval(tt.input, &tt.inputParams); err != nil || query != tt.wantQuery || !interfaceSliceEqual(params, tt.wantParams) {
+				if err != nil {
+					t.Error(err)
+				}
+				if query != tt.wantQuery {
+					t.Errorf("Doesn't Match\nexpected: \n%s\n but got: \n%s\n", tt.wantQuery, query)
+				}
+				if !interfaceSliceEqual(params, tt.wantParams) {
+					t.Errorf("Doesn't Match\nexpected: \n%v\n but got: \n%v\n", tt.wantParams, params)
+				}
+			}
+		})
+	}
+}
+
+func TestEvalNestedAbnormal(t *testing.T) {
+	tests := []struct {
+		name        string
+		input       string
+		inputParams NestedInfo
+		wantError   string
+	}{
+		{
+			name:        "no field",
+			input:       `SELECT * FROM person WHERE dept_no = /*filter.dept.number*/1`,
+			inputParams: NestedInfo{Filter: Filter{Dept: &Dept{No: 10}}},
+			wantError:   "no parameter that matches the bind value: filter.dept.number (filter.dept has no field number)",
+		},
+		{
+			name:        "nil",
+			input:       `SELECT * FROM person WHERE dept_no = /*optional.dept.no*/1`,
+			inputParams: NestedInfo{Optional: &Filter{}},
+			wantError:   "no parameter that matches the bind value: optional.dept.no (optional.dept is nil)",
+		},
+		{
+			name:        "no parameter",
+			input:       `SELECT * FROM person WHERE dept_no = /*filters.dept.no*/1`,
+			inputParams: NestedInfo{},
+			wantError:   "no parameter that matches the bind value: filters.dept.no",
+		},
+		{
+			name:        "condition",
+			input:       `SELECT * FROM person /* IF filter.dept.no > 1 */ WHERE dept_no = 1 /* END */`,
+			inputParams: NestedInfo{},
+			wantError:   `can not evaluate condition "filter.dept.no > 1": undefined variable: filter.dept.no (filter.dept is nil)`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, _, err := Eval(tt.input, &tt.inputParams); err == nil || err.Error() != tt.wantError {
+				if err == nil {
+					t.Errorf("should return error")
+				} else {
+					t.Errorf("\nexpected:\n%v\nbut got\n%v\n", tt.wantError, err.Error())
+				}
+			}
+		})
+	}
+}
+
+type DeptNos []int64
+
+type testUUID [4]byte
+
+func TestEvalSlice(t *testing.T) {
+	day1 := time.Date(2021, 1, 1, 0, 0, 0, 0, time.UTC)
+	day2 := time.Date(2021, 1, 2, 0, 0, 0, 0, time.UTC)
+	id1, id2 := testUUID{1, 2, 3, 4}, testUUID{5, 6, 7, 8}
+
+	tests := []struct {
+		name        string
+		input       string
+		inputParams map[string]interface{}
+		options     []Option
+		wantQuery   string
+		wantParams  []interface{}
+	}{
+		{
+			name:        "int64",
+			input:       `SELECT * FROM person WHERE dept_no IN /*values*/(1)`,
+			inputParams: map[string]interface{}{"values": []int64{1, 2}},
+			wantQuery:   `SELECT * FROM person WHERE dept_no IN (?, ?)/*values*/`,
+			wantParams:  []interface{}{int64(1), int64(2)},
+		},
+		{
+			name:        "named slice type",
+			input:       `SELECT * FROM person WHERE dept_no IN /*values*/(1)`,
+			inputParams: map[string]interface{}{"values": DeptNos{3, 4, 5}},
+			wantQuery:   `SELECT * FROM person WHERE dept_no IN (?, ?, ?)/*values*/`,
+			wantParams:  []interface{}{int64(3), int64(4), int64(5)},
+		},
+		{
+			name:        "float64",
+			input:       `SELECT * FROM person WHERE rate IN /*values*/(1.0)`,
+			inputParams: map[string]interface{}{"values": []float64{1.5}},
+			wantQuery:   `SELECT * FROM person WHERE rate IN (?)/*values*/`,
+			wantParams:  []interface{}{1.5},
+		},
+		{
+			name:        "time",
+			input:       `SELECT * FROM person WHERE created_on IN /*values*/('2000-01-01')`,
+			inputParams: map[string]interface{}{"values": []time.Time{day1, day2}},
+			wantQuery:   `SELECT * FROM person WHERE created_on IN (?, ?)/*values*/`,
+			wantParams:  []interface{}{day1, day2},
+		},
+		{
+			name:        "array",
+			input:       `SELECT * FROM person WHERE dept_no IN /*values*/(1)`,
+			inputParams: map[string]interface{}{"values": [2]int{6, 7}},
+			wantQuery:   `SELECT * FROM person WHERE dept_no IN (?, ?)/*values*/`,
+			wantParams:  []interface{}{6, 7},
+		},
+		{
+			name:        "slice of byte arrays",
+			input:       `SELECT * FROM person WHERE id IN /*values*/('')`,
+			inputParams: map[string]interface{}{"values": []testUUID{id1, id2}},
+			wantQuery:   `SELECT * FROM person WHERE id IN (?, ?)/*values*/`,
+			wantParams:  []interface{}{id1, id2},
+		},
+		{
+			name:        "byte array is scalar",
+			input:       `SELECT * FROM person WHERE id = /*value*/''`,
+			inputParams: map[string]interface{}{"value": id1},
+			wantQuery:   `SELECT * FROM person WHERE id = ?/*value*/`,
+			wantParams:  []interface{}{id1},
+		},
+		{
+			name:        "empty slice",
+			input:       `SELECT * FROM person WHERE dept_no IN /*values*/(1)`,
+			inputParams: map[string]interface{}{"values": []int64{}},
+			wantQuery:   `SELECT * FROM person WHERE dept_no IN (NULL)/*values*/`,
+			wantParams:  []interface{}{},
+		},
+		{
+			name:        "nil slice",
+			input:       `SELECT * FROM person WHERE dept_no IN /*values*/(1)`,
+			inputParams: map[string]interface{}{"values": []string(nil)},
+			wantQuery:   `SELECT * FROM person WHERE dept_no IN (NULL)/*values*/`,
+			wantParams:  []interface{}{},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if query, params, err := Eval(tt.input, tt.inputParams, tt.options...); err != nil || query != tt.wantQuery || !interfaceSliceEqual(params, tt.wantParams) {
+				if err != nil {
+					t.Error(err)
+				}
+				if query != tt.wantQuery {
+					t.Errorf("Doesn't Match\nexpected: \n%s\n but got: \n%s\n", tt.wantQuery, query)
+				}
+				if !interfaceSliceEqual(params, tt.wantParams) {
+					t.Errorf("Doesn't Match\nexpected: \n%v\n but got: \n%v\n", tt.wantParams, params)
+				}
+			}
+		})
+	}
+}
+
+func TestEvalByteSlice(t *testing.T) {
+	blob := []byte("abc")
+	_, params, err := Eval(`UPDATE person SET photo = /*photo*/'' WHERE employee_no = 1`, map[string]interface{}{"photo": blob})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(params) != 1 || !bytes.Equal(params[0].([]byte), blob) {
+		t.Errorf("Doesn't Match\nexpected: \n%v\n but got: \n%v\n", []interface{}{blob}, params)
+	}
+}
+
+func TestEvalEmptySliceError(t *testing.T) {
+	wantError := "empty slice for the bind value: values"
+	_, _, err := Eval(`SELECT * FROM person WHERE dept_no IN /*values*/(1)`, map[string]interface{}{"values": []int{}}, WithEmptySlice(EmptySliceError))
+	if err == nil || err.Error() != wantError {
+		t.Errorf("\nexpected:\n%v\nbut got\n%v\n", wantError, err)
+	}
+}
+
+func TestEvalSliceOfUnbindable(t *testing.T) {
+	tests := []struct {
+		name      string
+		values    interface{}
+		wantError string
+	}{
+		{
+			name:      "map",
+			values:    []interface{}{map[string]interface{}{"a": 1}},
+			wantError: "can not bind map[string]interface {} in the slice for the bind value: values",
+		},
+		{
+			name:      "struct",
+			values:    []Dept{{No: 1}},
+			wantError: "can not bind twowaysql.Dept in the slice for the bind value: values",
+		},
+		{
+			name:      "pointer to struct",
+			values:    []*Dept{{No: 1}},
+			wantError: "can not bind *twowaysql.Dept in the slice for the bind value: values",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, _, err := Eval(`SELECT * FROM person WHERE dept_no IN /*values*/(1)`, map[string]interface{}{"values": tt.values})
+			if err == nil || err.Error() != tt.wantError {
+				t.Errorf("\nexpected:\n%v\nbut got\n%v\n", tt.wantError, err)
+			}
+		})
+	}
+}
+
 func TestGenerateAbnormal(t *testing.T) {
 	tests := []struct {
 		name      string
@@ -626,6 +1018,11 @@ func TestGenerateAbnormal(t *testing.T) {
 			input:     `SELECT * FROM person WHERE employee_no < 1000 /* ELIF true */ AND dept_no = 1`,
 			wantError: "can not generate abstract syntax tree",
 		},
+		{
+			name:      "invalid condition",
+			input:     `SELECT * FROM person WHERE employee_no < 1000 /* IF deptNo == */ AND dept_no = 1 /* END */`,
+			wantError: `can not parse condition "deptNo ==": expected operand, but got end of condition`,
+		},
 		{
 			name:      "not match if, elif and end",
 			input:     `SELECT * FROM person WHERE employee_no < 1000 /* IF true */ /* IF false */ AND dept_no =1 /* ELSE */ AND id=3 /* ELSE*/ AND boss_id=4 /* END */`,
@@ -0,0 +1,26 @@
+package twowaysql
+
+// ConditionEvaluator compiles the conditions of /* IF ... */ and /* ELIF ... */.
+// A condition is compiled once when the template is compiled and evaluated on every Eval.
+// The default evaluator is NativeConditionEvaluator. Use WithConditionEvaluator to replace it.
+type ConditionEvaluator interface {
+	Compile(condition string) (Condition, error)
+}
+
+// Condition is a compiled condition.
+// Evaluate is called concurrently when a template is shared between goroutines.
+type Condition interface {
+	Evaluate(params map[string]interface{}) (bool, error)
+}
+
+// NativeConditionEvaluator returns the built-in condition evaluator.
+// See the README for the supported expression language.
+func NativeConditionEvaluator() ConditionEvaluator {
+	return nativeEvaluator{}
+}
+
+type nativeEvaluator struct{}
+
+func (nativeEvaluator) Compile(condition string) (Condition, error) {
+	return compileCondition(condition)
+}
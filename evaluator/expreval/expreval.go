@@ -0,0 +1,52 @@
+// Package expreval provides a twowaysql.ConditionEvaluator backed by github.com/expr-lang/expr.
+//
+// Conditions are written in the expr language, for example
+//
+//	/* IF deptNo > 10 && name in ["HR", "GA"] */
+//
+// Conditions must evaluate to bool.
+package expreval
+
+import (
+	"fmt"
+
+	"github.com/expr-lang/expr"
+	"github.com/expr-lang/expr/vm"
+	"github.com/future-architect/go-twowaysql"
+)
+
+// New returns a ConditionEvaluator that compiles conditions with expr.
+// opts are passed to expr.Compile.
+func New(opts ...expr.Option) twowaysql.ConditionEvaluator {
+	return evaluator{opts: opts}
+}
+
+type evaluator struct {
+	opts []expr.Option
+}
+
+func (e evaluator) Compile(condition string) (twowaysql.Condition, error) {
+	program, err := expr.Compile(condition, e.opts...)
+	if err != nil {
+		return nil, err
+	}
+	return &exprCondition{src: condition, program: program}, nil
+}
+
+type exprCondition struct {
+	src     string
+	program *vm.Program
+}
+
+// Evaluate runs the compiled program. A program is safe for concurrent use.
+func (c *exprCondition) Evaluate(params map[string]interface{}) (bool, error) {
+	result, err := expr.Run(c.program, params)
+	if err != nil {
+		return false, err
+	}
+	truth, ok := result.(bool)
+	if !ok {
+		return false, fmt.Errorf("condition %q returned %T, but must return bool", c.src, result)
+	}
+	return truth, nil
+}
@@ -0,0 +1,60 @@
+package expreval
+
+import (
+	"testing"
+
+	"github.com/future-architect/go-twowaysql"
+)
+
+type Info struct {
+	Name    string `twowaysql:"name"`
+	DeptNo  int    `twowaysql:"deptNo"`
+	IntList []int  `twowaysql:"int_list"`
+}
+
+func TestEval(t *testing.T) {
+	tests := []struct {
+		name        string
+		input       string
+		inputParams Info
+		wantQuery   string
+	}{
+		{
+			name:        "equal",
+			input:       `SELECT * FROM person WHERE employee_no < 1000 /* IF deptNo == 15 */ AND dept_no = 1 /* END */`,
+			inputParams: Info{DeptNo: 15},
+			wantQuery:   `SELECT * FROM person WHERE employee_no < 1000 AND dept_no = 1`,
+		},
+		{
+			name:        "builtin",
+			input:       `SELECT * FROM person WHERE employee_no < 1000 /* IF name startsWith "H" && len(int_list) > 0 */ AND dept_no = 1 /* END */`,
+			inputParams: Info{Name: "HR"},
+			wantQuery:   `SELECT * FROM person WHERE employee_no < 1000`,
+		},
+		{
+			name:        "in",
+			input:       `SELECT * FROM person WHERE employee_no < 1000 /* IF name in ["HR", "GA"] */ AND dept_no = 1 /* END */`,
+			inputParams: Info{Name: "GA"},
+			wantQuery:   `SELECT * FROM person WHERE employee_no < 1000 AND dept_no = 1`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			query, _, err := twowaysql.Eval(tt.input, &tt.inputParams, twowaysql.WithConditionEvaluator(New()))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if query != tt.wantQuery {
+				t.Errorf("Doesn't Match\nexpected: \n%s\n but got: \n%s\n", tt.wantQuery, query)
+			}
+		})
+	}
+}
+
+func TestNotBool(t *testing.T) {
+	_, _, err := twowaysql.Eval(`SELECT * FROM person /* IF deptNo */ WHERE dept_no = 1 /* END */`, &Info{DeptNo: 1}, twowaysql.WithConditionEvaluator(New()))
+	if err == nil || err.Error() != `condition "deptNo" returned int, but must return bool` {
+		t.Errorf("unexpected error: %v", err)
+	}
+}
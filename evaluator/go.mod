@@ -0,0 +1,19 @@
+module github.com/future-architect/go-twowaysql/evaluator
+
+go 1.23
+
+require (
+	github.com/expr-lang/expr v1.17.8
+	github.com/future-architect/go-twowaysql v0.0.0-20261017025504-d0c226fa2e61
+	github.com/robertkrimen/otto v0.2.1
+)
+
+require (
+	github.com/jmoiron/sqlx v1.3.1 // indirect
+	gitlab.com/osaki-lab/tagscanner v0.1.2 // indirect
+	golang.org/x/text v0.4.0 // indirect
+	gopkg.in/sourcemap.v1 v1.0.5 // indirect
+)
+
+// ローカルで開発するときは親ディレクトリを使う。依存として使われるときはreplaceは無視され、上のバージョンが使われる
+replace github.com/future-architect/go-twowaysql => ../
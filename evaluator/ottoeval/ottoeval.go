@@ -0,0 +1,51 @@
+// Package ottoeval provides a twowaysql.ConditionEvaluator that evaluates conditions as JavaScript with otto.
+// It keeps the semantics of conditions before the built-in expression language was introduced.
+package ottoeval
+
+import (
+	"github.com/future-architect/go-twowaysql"
+	"github.com/robertkrimen/otto"
+)
+
+// New returns a ConditionEvaluator that runs conditions on the otto JavaScript interpreter.
+func New() twowaysql.ConditionEvaluator {
+	return evaluator{}
+}
+
+type evaluator struct{}
+
+func (evaluator) Compile(condition string) (twowaysql.Condition, error) {
+	// 構文エラーを早期に検出するためにコンパイルしておく
+	script, err := otto.New().Compile("", condition)
+	if err != nil {
+		return nil, err
+	}
+	return &ottoCondition{script: script}, nil
+}
+
+type ottoCondition struct {
+	script *otto.Script
+}
+
+// Evaluate runs the script on a new VM because an otto VM must not be shared between goroutines.
+func (c *ottoCondition) Evaluate(params map[string]interface{}) (bool, error) {
+	vm := otto.New()
+	for key, value := range params {
+		err := vm.Set(key, value)
+		if err != nil {
+			return false, err
+		}
+	}
+
+	result, err := vm.Run(c.script)
+	if err != nil {
+		return false, err
+	}
+
+	truth, err := result.ToBoolean()
+	if err != nil {
+		return false, err
+	}
+
+	return truth, nil
+}
@@ -0,0 +1,60 @@
+package ottoeval
+
+import (
+	"testing"
+
+	"github.com/future-architect/go-twowaysql"
+)
+
+type Info struct {
+	Name     string `twowaysql:"name"`
+	DeptNo   int    `twowaysql:"deptNo"`
+	MaxEmpNo int    `twowaysql:"maxEmpNo"`
+	IntList  []int  `twowaysql:"int_list"`
+}
+
+func TestEval(t *testing.T) {
+	tests := []struct {
+		name        string
+		input       string
+		inputParams Info
+		wantQuery   string
+	}{
+		{
+			name:        "strict equal",
+			input:       `SELECT * FROM person WHERE employee_no < 1000 /* IF deptNo === 15 */ AND dept_no = 1 /* END */`,
+			inputParams: Info{DeptNo: 15},
+			wantQuery:   `SELECT * FROM person WHERE employee_no < 1000 AND dept_no = 1`,
+		},
+		{
+			name:        "javascript method",
+			input:       `SELECT * FROM person WHERE employee_no < 1000 /* IF name.indexOf("H") === 0 */ AND dept_no = 1 /* END */`,
+			inputParams: Info{Name: "HR"},
+			wantQuery:   `SELECT * FROM person WHERE employee_no < 1000 AND dept_no = 1`,
+		},
+		{
+			name:        "not null",
+			input:       `SELECT * FROM person /* IF int_list !== null */ WHERE person.gender in /*int_list*/(3,5,7) /* END */`,
+			inputParams: Info{IntList: []int{1, 2}},
+			wantQuery:   `SELECT * FROM person WHERE person.gender in (?, ?)/*int_list*/`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			query, _, err := twowaysql.Eval(tt.input, &tt.inputParams, twowaysql.WithConditionEvaluator(New()))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if query != tt.wantQuery {
+				t.Errorf("Doesn't Match\nexpected: \n%s\n but got: \n%s\n", tt.wantQuery, query)
+			}
+		})
+	}
+}
+
+func TestCompileError(t *testing.T) {
+	if _, err := twowaysql.Compile(`SELECT * FROM person /* IF deptNo === */ WHERE dept_no = 1 /* END */`, twowaysql.WithConditionEvaluator(New())); err == nil {
+		t.Error("should return error")
+	}
+}
@@ -0,0 +1,66 @@
+package twowaysql
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+)
+
+// flagEvaluatorは条件式をパラメータ名とみなし、その真偽値を返す
+type flagEvaluator struct {
+	compiled *int
+}
+
+func (e flagEvaluator) Compile(condition string) (Condition, error) {
+	*e.compiled++
+	if condition == "" {
+		return nil, errors.New("empty condition")
+	}
+	return flagCondition(condition), nil
+}
+
+type flagCondition string
+
+func (c flagCondition) Evaluate(params map[string]interface{}) (bool, error) {
+	v, ok := params[string(c)].(bool)
+	if !ok {
+		return false, fmt.Errorf("%s is not bool", string(c))
+	}
+	return v, nil
+}
+
+func TestWithConditionEvaluator(t *testing.T) {
+	compiled := 0
+	tmpl, err := Compile(`SELECT * FROM person WHERE employee_no < 1000 /* IF checked */ AND dept_no = 1 /* ELIF unchecked */ AND boss_no = 2 /* END */`, WithConditionEvaluator(flagEvaluator{compiled: &compiled}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if compiled != 2 {
+		t.Errorf("conditions should be compiled once: compiled %d times", compiled)
+	}
+
+	for _, tt := range []struct {
+		params Info
+		want   string
+	}{
+		{params: Info{Checked: true}, want: `SELECT * FROM person WHERE employee_no < 1000 AND dept_no = 1`},
+		{params: Info{Unchecked: true}, want: `SELECT * FROM person WHERE employee_no < 1000 AND boss_no = 2`},
+		{params: Info{}, want: `SELECT * FROM person WHERE employee_no < 1000`},
+	} {
+		query, _, err := tmpl.Eval(&tt.params)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if query != tt.want {
+			t.Errorf("Doesn't Match\nexpected: \n%s\n but got: \n%s\n", tt.want, query)
+		}
+	}
+	if compiled != 2 {
+		t.Errorf("conditions should not be compiled on Eval: compiled %d times", compiled)
+	}
+
+	_, _, err = Eval(`SELECT * FROM person /* IF name */ WHERE name = /*name*/'' /* END */`, &Info{Name: "HR"}, WithConditionEvaluator(flagEvaluator{compiled: &compiled}))
+	if err == nil || err.Error() != "name is not bool" {
+		t.Errorf("unexpected error: %v", err)
+	}
+}
@@ -105,3 +105,52 @@ func ExampleEval() {
 	// [3 1 2 3]
 
 }
+
+func ExampleCompile() {
+
+	type Info struct {
+		MaxEmpNo int `twowaysql:"maxEmpNo"`
+		DeptNo   int `twowaysql:"deptNo"`
+	}
+
+	// parse once, evaluate many times
+	tmpl := twowaysql.MustCompile(`SELECT * FROM person WHERE employee_no < /*maxEmpNo*/1000 /* IF deptNo */ AND dept_no < /*deptNo*/1 /* END */`)
+
+	for _, params := range []Info{{MaxEmpNo: 3, DeptNo: 12}, {MaxEmpNo: 5}} {
+		after, afterParams, _ := tmpl.Eval(&params)
+		fmt.Println(after)
+		fmt.Println(afterParams)
+	}
+
+	// Output:
+	// SELECT * FROM person WHERE employee_no < ?/*maxEmpNo*/ AND dept_no < ?/*deptNo*/
+	// [3 12]
+	// SELECT * FROM person WHERE employee_no < ?/*maxEmpNo*/
+	// [5]
+}
+
+func ExampleEvalNamed() {
+
+	type Info struct {
+		MaxEmpNo   int      `twowaysql:"maxEmpNo"`
+		GenderList []string `twowaysql:"gender_list"`
+	}
+
+	var params = Info{
+		MaxEmpNo:   3,
+		GenderList: []string{"M", "F"},
+	}
+
+	after, afterParams, _ := twowaysql.EvalNamed(`SELECT * FROM person WHERE employee_no < /*maxEmpNo*/1000 AND gender IN /*gender_list*/('M')`, &params)
+
+	fmt.Println(after)
+	for _, arg := range afterParams {
+		fmt.Printf("%s=%v\n", arg.Name, arg.Value)
+	}
+
+	// Output:
+	// SELECT * FROM person WHERE employee_no < :maxEmpNo/*maxEmpNo*/ AND gender IN (:gender_list, :gender_list_2)/*gender_list*/
+	// maxEmpNo=3
+	// gender_list=M
+	// gender_list_2=F
+}
@@ -0,0 +1,71 @@
+package twowaysql
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"reflect"
+
+	"github.com/jmoiron/sqlx"
+)
+
+// ErrTooManyRows is returned by Get in strict mode when the query returns more than one row.
+var ErrTooManyRows = errors.New("twowaysql: more than one row in result set")
+
+var scannerType = reflect.TypeOf((*sql.Scanner)(nil)).Elem()
+
+// getは一行だけ取り出してdestに読み込む
+// strictの場合は二行目があればErrTooManyRowsを返す
+func get(ctx context.Context, q sqlx.QueryerContext, strict bool, dest interface{}, query string, args ...interface{}) error {
+	if !strict {
+		return sqlx.GetContext(ctx, q, dest, query, args...)
+	}
+
+	rows, err := q.QueryxContext(ctx, query, args...)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	if !rows.Next() {
+		if err := rows.Err(); err != nil {
+			return err
+		}
+		return sql.ErrNoRows
+	}
+	if isScannable(reflect.TypeOf(dest)) {
+		err = rows.Scan(dest)
+	} else {
+		err = rows.StructScan(dest)
+	}
+	if err != nil {
+		return err
+	}
+	if rows.Next() {
+		return ErrTooManyRows
+	}
+	if err := rows.Err(); err != nil {
+		return err
+	}
+	return rows.Close()
+}
+
+// isScannableはsqlxと同じ基準でdestをScanで読み込むかを判定する
+// 構造体以外、sql.Scannerを実装する型、公開フィールドのない構造体はScanで読み込む
+func isScannable(t reflect.Type) bool {
+	if t.Kind() == reflect.Ptr {
+		if t.Implements(scannerType) {
+			return true
+		}
+		t = t.Elem()
+	}
+	if reflect.PtrTo(t).Implements(scannerType) || t.Kind() != reflect.Struct {
+		return true
+	}
+	for i := 0; i < t.NumField(); i++ {
+		if t.Field(i).PkgPath == "" {
+			return false
+		}
+	}
+	return true
+}
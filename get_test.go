@@ -0,0 +1,35 @@
+package twowaysql
+
+import (
+	"database/sql"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestIsScannable(t *testing.T) {
+	type unexported struct {
+		name string
+	}
+
+	tests := []struct {
+		name string
+		dest interface{}
+		want bool
+	}{
+		{name: "int", dest: new(int), want: true},
+		{name: "string", dest: new(string), want: true},
+		{name: "time", dest: new(time.Time), want: true},
+		{name: "scanner", dest: new(sql.NullString), want: true},
+		{name: "unexported fields", dest: new(unexported), want: true},
+		{name: "struct", dest: new(Person), want: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := isScannable(reflect.TypeOf(tt.dest)); got != tt.want {
+				t.Errorf("Doesn't Match\nexpected: %v\n but got: %v\n", tt.want, got)
+			}
+		})
+	}
+}
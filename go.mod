@@ -1,11 +1,10 @@
 module github.com/future-architect/go-twowaysql
 
-go 1.16
+go 1.23
 
 require (
+	github.com/go-sql-driver/mysql v1.5.0
 	github.com/jmoiron/sqlx v1.3.1
 	github.com/lib/pq v1.9.0
-	github.com/robertkrimen/otto v0.0.0-20200922221731-ef014fd054ac
 	gitlab.com/osaki-lab/tagscanner v0.1.2
-	gopkg.in/sourcemap.v1 v1.0.5 // indirect
 )
@@ -0,0 +1,180 @@
+package twowaysql
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/fs"
+	"sync"
+	"time"
+)
+
+// ErrNoLoader is returned by the *File methods when no Loader is given with WithLoader.
+var ErrNoLoader = errors.New("twowaysql: no loader is set, use WithLoader")
+
+// Loader reads 2WaySQL queries from files, compiles them and caches the templates.
+// Any fs.FS can be used, including embed.FS and os.DirFS.
+// A Loader is safe for concurrent use.
+type Loader struct {
+	fsys fs.FS
+	opts []Option
+
+	mu    sync.RWMutex
+	cache map[string]*Template
+
+	// Watchが最後に報告したエラー。同じエラーを繰り返し報告しない
+	failed map[string]string
+}
+
+// NewLoader returns a Loader reading from fsys. opts are used to compile the templates.
+func NewLoader(fsys fs.FS, opts ...Option) *Loader {
+	return &Loader{
+		fsys:   fsys,
+		opts:   opts,
+		cache:  map[string]*Template{},
+		failed: map[string]string{},
+	}
+}
+
+// Load returns the template of the file. name is a slash-separated path in the fs.FS such as "persons/find.sql".
+// The file is read and compiled only on the first call.
+func (l *Loader) Load(name string) (*Template, error) {
+	l.mu.RLock()
+	tmpl, ok := l.cache[name]
+	l.mu.RUnlock()
+	if ok {
+		return tmpl, nil
+	}
+
+	tmpl, err := l.compile(name)
+	if err != nil {
+		return nil, err
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	// 同時に読み込んだ場合は先に登録されたものを使う
+	if cached, ok := l.cache[name]; ok {
+		return cached, nil
+	}
+	l.cache[name] = tmpl
+	return tmpl, nil
+}
+
+// MustLoad is like Load but panics if the file cannot be read or compiled.
+func (l *Loader) MustLoad(name string) *Template {
+	tmpl, err := l.Load(name)
+	if err != nil {
+		panic(err)
+	}
+	return tmpl
+}
+
+// Watch is intended for development. It checks the loaded files every interval until ctx is done,
+// and recompiles the files that have changed so that edits are picked up without restarting.
+// The new template replaces the cached one atomically. If a file cannot be read or compiled,
+// the last good template is kept and the error is passed to onError, once until the file changes again.
+// onError may be nil. Watch blocks, so run it in its own goroutine. It returns ctx.Err(),
+// or an error without watching if interval is not positive.
+//
+//	go loader.Watch(ctx, time.Second, func(err error) { log.Println(err) })
+func (l *Loader) Watch(ctx context.Context, interval time.Duration, onError func(error)) error {
+	if interval <= 0 {
+		return fmt.Errorf("twowaysql: non-positive interval for Watch: %v", interval)
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-ticker.C:
+			l.reload(onError)
+		}
+	}
+}
+
+// reloadは読み込み済みのファイルを読み直し、内容が変わっていればコンパイルし直す
+// Templateは元のクエリを持っているので、更新日時ではなく内容で比べる。embed.FSのように更新日時がなくても使える
+func (l *Loader) reload(onError func(error)) {
+	l.mu.RLock()
+	cached := make(map[string]*Template, len(l.cache))
+	for name, tmpl := range l.cache {
+		cached[name] = tmpl
+	}
+	l.mu.RUnlock()
+
+	for name, old := range cached {
+		tmpl, err := l.recompile(name, old)
+		if err != nil {
+			l.mu.Lock()
+			report := l.failed[name] != err.Error()
+			l.failed[name] = err.Error()
+			l.mu.Unlock()
+			if report && onError != nil {
+				onError(err)
+			}
+			continue
+		}
+
+		l.mu.Lock()
+		delete(l.failed, name)
+		// 読み直している間に置き換えられた場合はそちらを優先する
+		if tmpl != old && l.cache[name] == old {
+			l.cache[name] = tmpl
+		}
+		l.mu.Unlock()
+	}
+}
+
+// recompileは内容が変わっていない場合はoldをそのまま返す
+func (l *Loader) recompile(name string, old *Template) (*Template, error) {
+	src, err := l.read(name)
+	if err != nil {
+		return nil, err
+	}
+	if src == old.query {
+		return old, nil
+	}
+	return l.compileSource(name, src)
+}
+
+func (l *Loader) compile(name string) (*Template, error) {
+	src, err := l.read(name)
+	if err != nil {
+		return nil, err
+	}
+	return l.compileSource(name, src)
+}
+
+func (l *Loader) read(name string) (string, error) {
+	src, err := fs.ReadFile(l.fsys, name)
+	if err != nil {
+		return "", fmt.Errorf("twowaysql: load %s: %w", name, err)
+	}
+	return string(src), nil
+}
+
+func (l *Loader) compileSource(name, src string) (*Template, error) {
+	tmpl, err := Compile(src, l.opts...)
+	if err != nil {
+		return nil, fmt.Errorf("twowaysql: compile %s: %w", name, err)
+	}
+	return tmpl, nil
+}
+
+// WithLoader sets the Loader used by SelectFile, GetFile, QueryFile and ExecFile.
+// The templates are compiled with the options of the Loader.
+func WithLoader(loader *Loader) Option {
+	return func(o *options) {
+		o.loader = loader
+	}
+}
+
+// loadはWithLoaderで指定されたLoaderからテンプレートを取り出す
+func (o *options) load(name string) (*Template, error) {
+	if o.loader == nil {
+		return nil, ErrNoLoader
+	}
+	return o.loader.Load(name)
+}
@@ -0,0 +1,186 @@
+package twowaysql
+
+import (
+	"context"
+	"embed"
+	"errors"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"testing/fstest"
+	"time"
+
+	"github.com/jmoiron/sqlx"
+)
+
+//go:embed testdata/sql
+var testSQL embed.FS
+
+func TestLoader(t *testing.T) {
+	sqlFS, err := fs.Sub(testSQL, "testdata/sql")
+	if err != nil {
+		t.Fatal(err)
+	}
+	loader := NewLoader(sqlFS)
+
+	tmpl, err := loader.Load("persons/find.sql")
+	if err != nil {
+		t.Fatal(err)
+	}
+	query, params, err := tmpl.Eval(&Info{MaxEmpNo: 3, DeptNo: 12})
+	if err != nil {
+		t.Fatal(err)
+	}
+	wantQuery := `SELECT first_name, last_name, email FROM persons WHERE employee_no < ?/*maxEmpNo*/ AND dept_no < ?/*deptNo*/ ORDER BY employee_no`
+	if query != wantQuery {
+		t.Errorf("Doesn't Match\nexpected: \n%s\n but got: \n%s\n", wantQuery, query)
+	}
+	if !interfaceSliceEqual(params, []interface{}{3, 12}) {
+		t.Errorf("Doesn't Match\nexpected: \n%v\n but got: \n%v\n", []interface{}{3, 12}, params)
+	}
+
+	// 二回目以降はキャッシュを使う
+	if cached := loader.MustLoad("persons/find.sql"); cached != tmpl {
+		t.Error("template should be cached")
+	}
+}
+
+func TestLoaderConcurrent(t *testing.T) {
+	loader := NewLoader(fstest.MapFS{
+		"find.sql": {Data: []byte(`SELECT * FROM persons WHERE employee_no < /*maxEmpNo*/1000`)},
+	})
+
+	var wg sync.WaitGroup
+	templates := make([]*Template, 10)
+	for i := range templates {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			templates[i] = loader.MustLoad("find.sql")
+		}(i)
+	}
+	wg.Wait()
+	for _, tmpl := range templates {
+		if tmpl != templates[0] {
+			t.Fatal("all goroutines should get the same template")
+		}
+	}
+}
+
+func TestLoaderAbnormal(t *testing.T) {
+	loader := NewLoader(fstest.MapFS{
+		"broken.sql": {Data: []byte(`SELECT * FROM persons /* IF deptNo */`)},
+	})
+
+	_, err := loader.Load("missing.sql")
+	if !errors.Is(err, fs.ErrNotExist) {
+		t.Errorf("should return fs.ErrNotExist: %v", err)
+	}
+
+	wantError := "twowaysql: compile broken.sql: can not parse: expected /* END */, but got 7"
+	if _, err := loader.Load("broken.sql"); err == nil || err.Error() != wantError {
+		t.Errorf("\nexpected:\n%v\nbut got\n%v\n", wantError, err)
+	}
+}
+
+func TestLoaderReload(t *testing.T) {
+	fsys := fstest.MapFS{
+		"find.sql": {Data: []byte(`SELECT * FROM persons WHERE employee_no < /*maxEmpNo*/1000`)},
+	}
+	loader := NewLoader(fsys)
+	first := loader.MustLoad("find.sql")
+	var errs []error
+	onError := func(err error) {
+		errs = append(errs, err)
+	}
+
+	// 変更がなければそのまま使う
+	loader.reload(onError)
+	if loader.MustLoad("find.sql") != first {
+		t.Error("template should not be replaced when the file is not changed")
+	}
+
+	fsys["find.sql"] = &fstest.MapFile{Data: []byte(`SELECT * FROM persons WHERE employee_no < /*maxEmpNo*/1000 /* IF deptNo */`)}
+	loader.reload(onError)
+	loader.reload(onError)
+	if loader.MustLoad("find.sql") != first {
+		t.Error("last good template should be kept when the file is broken")
+	}
+	wantError := "twowaysql: compile find.sql: can not parse: expected /* END */, but got 7"
+	if len(errs) != 1 || errs[0].Error() != wantError {
+		t.Errorf("\nexpected:\n%v\nbut got\n%v\n", []string{wantError}, errs)
+	}
+
+	fsys["find.sql"] = &fstest.MapFile{Data: []byte(`SELECT * FROM persons WHERE dept_no = /*deptNo*/1`)}
+	loader.reload(onError)
+	query, _, err := loader.MustLoad("find.sql").Eval(&Info{DeptNo: 1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	wantQuery := `SELECT * FROM persons WHERE dept_no = ?/*deptNo*/`
+	if query != wantQuery {
+		t.Errorf("Doesn't Match\nexpected: \n%s\n but got: \n%s\n", wantQuery, query)
+	}
+
+	delete(fsys, "find.sql")
+	loader.reload(onError)
+	if len(errs) != 2 || !errors.Is(errs[1], fs.ErrNotExist) {
+		t.Errorf("should report fs.ErrNotExist: %v", errs)
+	}
+}
+
+func TestLoaderWatch(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "find.sql")
+	if err := os.WriteFile(path, []byte(`SELECT * FROM persons`), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	loader := NewLoader(os.DirFS(dir))
+	first := loader.MustLoad("find.sql")
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error)
+	go func() {
+		done <- loader.Watch(ctx, 10*time.Millisecond, nil)
+	}()
+
+	if err := os.WriteFile(path, []byte(`SELECT * FROM persons WHERE dept_no = /*deptNo*/1`), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	deadline := time.Now().Add(5 * time.Second)
+	for loader.MustLoad("find.sql") == first {
+		if time.Now().After(deadline) {
+			t.Fatal("changed file should be reloaded")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	cancel()
+	if err := <-done; !errors.Is(err, context.Canceled) {
+		t.Errorf("\nexpected:\n%v\nbut got\n%v\n", context.Canceled, err)
+	}
+}
+
+func TestFileWithoutLoader(t *testing.T) {
+	db, err := sqlx.Open("postgres", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+
+	var people []Person
+	if err := New(db).SelectFile(context.Background(), &people, "persons/find.sql", nil); !errors.Is(err, ErrNoLoader) {
+		t.Errorf("\nexpected:\n%v\nbut got\n%v\n", ErrNoLoader, err)
+	}
+}
+
+func TestLoaderWatchInterval(t *testing.T) {
+	loader := NewLoader(os.DirFS(t.TempDir()))
+	for _, interval := range []time.Duration{0, -time.Second} {
+		if err := loader.Watch(context.Background(), interval, nil); err == nil {
+			t.Errorf("interval %v: should return error", interval)
+		}
+	}
+}
@@ -0,0 +1,116 @@
+package twowaysql
+
+import (
+	"fmt"
+	"reflect"
+	"regexp"
+)
+
+// loop is a parsed /* FOR item IN items */ directive.
+//
+// In the body of the loop, the following variables are available
+// in addition to the parameters:
+//
+//	item        the current element
+//	item_index  the index of the current element, starting at 0
+//	item_first  true for the first element
+//	item_last   true for the last element
+//
+// With /* FOR item IN items SEPARATOR ',' */ the separator is written between the elements.
+type loop struct {
+	name      string
+	source    string
+	separator string
+}
+
+var loopPattern = regexp.MustCompile(`^\s*([A-Za-z_][A-Za-z0-9_]*)\s+IN\s+([A-Za-z_][A-Za-z0-9_]*(?:\.[A-Za-z_][A-Za-z0-9_]*)*)(?:\s+SEPARATOR\s+('[^']*'|"[^"]*"))?\s*$`)
+
+// parseLoopはFORの後ろの item IN items SEPARATOR ',' を解析する
+func parseLoop(src string) (*loop, error) {
+	m := loopPattern.FindStringSubmatch(src)
+	if m == nil {
+		return nil, fmt.Errorf("can not parse FOR %q: expected \"item IN items\" or \"item IN items SEPARATOR ','\"", src)
+	}
+	l := &loop{
+		name:   m[1],
+		source: m[2],
+	}
+	if m[3] != "" {
+		l.separator = m[3][1 : len(m[3])-1]
+	}
+	return l, nil
+}
+
+// itemsはループ対象のスライスを取り出す。nilの場合は0回のループになる
+func (l *loop) items(sc *scope) (reflect.Value, error) {
+	v, err := sc.lookup(l.source)
+	if err != nil {
+		return reflect.Value{}, fmt.Errorf("no parameter that matches the FOR value: %s", describeMissing(l.source, err))
+	}
+	rv := reflect.ValueOf(v)
+	for rv.Kind() == reflect.Ptr || rv.Kind() == reflect.Interface {
+		if rv.IsNil() {
+			return reflect.ValueOf([]interface{}{}), nil
+		}
+		rv = rv.Elem()
+	}
+	switch rv.Kind() {
+	case reflect.Slice, reflect.Array:
+		return rv, nil
+	case reflect.Invalid:
+		return reflect.ValueOf([]interface{}{}), nil
+	}
+	return reflect.Value{}, fmt.Errorf("FOR value must be a slice or an array: %s is %T", l.source, v)
+}
+
+// scopeはFORの中で参照できる変数を表す。ループ変数はパラメータの上に重ねる
+type scope struct {
+	vars   map[string]interface{}
+	parent *scope
+	flat   map[string]interface{}
+}
+
+// enterはループのi番目の要素を参照できるscopeを作る
+func (l *loop) enter(sc *scope, items reflect.Value, i int) *scope {
+	return &scope{
+		vars: map[string]interface{}{
+			l.name:            items.Index(i).Interface(),
+			l.name + "_index": i,
+			l.name + "_first": i == 0,
+			l.name + "_last":  i == items.Len()-1,
+		},
+		parent: sc,
+	}
+}
+
+func (s *scope) get(name string) (interface{}, bool) {
+	for ; s != nil; s = s.parent {
+		if v, ok := s.vars[name]; ok {
+			return v, true
+		}
+	}
+	return nil, false
+}
+
+// lookupはname.field.fieldの形式の参照を解決する
+func (s *scope) lookup(path string) (interface{}, error) {
+	return lookupPath(s.get, path)
+}
+
+// paramsは条件式の評価に渡すために外側の変数も含めたmapを返す
+func (s *scope) params() map[string]interface{} {
+	if s.parent == nil {
+		return s.vars
+	}
+	if s.flat == nil {
+		parent := s.parent.params()
+		s.flat = make(map[string]interface{}, len(parent)+len(s.vars))
+		for k, v := range parent {
+			s.flat[k] = v
+		}
+		for k, v := range s.vars {
+			s.flat[k] = v
+		}
+	}
+	return s.flat
+}
@@ -0,0 +1,151 @@
+package twowaysql
+
+import (
+	"testing"
+)
+
+type Member struct {
+	Name   string   `twowaysql:"name"`
+	Age    int      `twowaysql:"age"`
+	Emails []string `twowaysql:"emails"`
+}
+
+type LoopInfo struct {
+	Members []Member `twowaysql:"members"`
+	DeptNo  int      `twowaysql:"deptNo"`
+	Names   []string `twowaysql:"names"`
+}
+
+func TestEvalFor(t *testing.T) {
+	members := []Member{
+		{Name: "Jeff", Age: 30, Emails: []string{"jeff@example.com"}},
+		{Name: "Tim", Age: 40, Emails: []string{"tim@example.com", "cook@example.com"}},
+		{Name: "Evan", Age: 50},
+	}
+
+	tests := []struct {
+		name        string
+		input       string
+		inputParams LoopInfo
+		wantQuery   string
+		wantParams  []interface{}
+	}{
+		{
+			name:        "multi row insert",
+			input:       `INSERT INTO persons (first_name, age, dept_no) VALUES /* FOR m IN members SEPARATOR ',' */(/*m.name*/'Tim', /*m.age*/1, /*deptNo*/1)/* END */`,
+			inputParams: LoopInfo{Members: members, DeptNo: 10},
+			wantQuery:   `INSERT INTO persons (first_name, age, dept_no) VALUES (?/*m.name*/, ?/*m.age*/, ?/*deptNo*/),(?/*m.name*/, ?/*m.age*/, ?/*deptNo*/),(?/*m.name*/, ?/*m.age*/, ?/*deptNo*/)`,
+			wantParams:  []interface{}{"Jeff", 30, 10, "Tim", 40, 10, "Evan", 50, 10},
+		},
+		{
+			name:        "or chain with separator",
+			input:       `SELECT * FROM persons WHERE /* FOR name IN names SEPARATOR 'OR' */ first_name = /*name*/'Tim' /* END */`,
+			inputParams: LoopInfo{Names: []string{"Jeff", "Tim"}},
+			wantQuery:   `SELECT * FROM persons WHERE first_name = ?/*name*/ OR first_name = ?/*name*/`,
+			wantParams:  []interface{}{"Jeff", "Tim"},
+		},
+		{
+			name:        "first",
+			input:       `SELECT * FROM persons WHERE /* FOR m IN members */ /* IF !m_first */ OR /* END */ (first_name = /*m.name*/'Tim' AND age = /*m_index*/0) /* END */ ORDER BY age`,
+			inputParams: LoopInfo{Members: members[:2]},
+			wantQuery:   `SELECT * FROM persons WHERE (first_name = ?/*m.name*/ AND age = ?/*m_index*/) OR (first_name = ?/*m.name*/ AND age = ?/*m_index*/) ORDER BY age`,
+			wantParams:  []interface{}{"Jeff", 0, "Tim", 1},
+		},
+		{
+			name:        "last",
+			input:       `SELECT * FROM persons WHERE /* FOR m IN members */first_name = /*m.name*/'Tim'/* IF !m_last */ OR /* END *//* END */`,
+			inputParams: LoopInfo{Members: members},
+			wantQuery:   `SELECT * FROM persons WHERE first_name = ?/*m.name*/ OR first_name = ?/*m.name*/ OR first_name = ?/*m.name*/`,
+			wantParams:  []interface{}{"Jeff", "Tim", "Evan"},
+		},
+		{
+			name:        "condition on item",
+			input:       `SELECT * FROM persons WHERE dept_no = /*deptNo*/1 /* FOR m IN members */ /* IF m.age >= 40 */ AND age <> /*m.age*/1 /* END */ /* END */`,
+			inputParams: LoopInfo{Members: members, DeptNo: 3},
+			wantQuery:   `SELECT * FROM persons WHERE dept_no = ?/*deptNo*/ AND age <> ?/*m.age*/ AND age <> ?/*m.age*/`,
+			wantParams:  []interface{}{3, 40, 50},
+		},
+		{
+			name:        "nested",
+			input:       `SELECT * FROM emails WHERE address IN (/* FOR m IN members SEPARATOR ',' */ /* FOR e IN m.emails SEPARATOR ',' */ /*e*/'a' /* END */ /* END */)`,
+			inputParams: LoopInfo{Members: members},
+			wantQuery:   `SELECT * FROM emails WHERE address IN ( ?/*e*/ , ?/*e*/ , ?/*e*/ )`,
+			wantParams:  []interface{}{"jeff@example.com", "tim@example.com", "cook@example.com"},
+		},
+		{
+			name:        "empty",
+			input:       `SELECT * FROM persons WHERE dept_no = /*deptNo*/1 /* FOR name IN names */ AND first_name <> /*name*/'Tim' /* END */ ORDER BY age`,
+			inputParams: LoopInfo{DeptNo: 3},
+			wantQuery:   `SELECT * FROM persons WHERE dept_no = ?/*deptNo*/ ORDER BY age`,
+			wantParams:  []interface{}{3},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if query, params, err := Eval(tt.input, &tt.inputParams); err != nil || query != tt.wantQuery || !interfaceSliceEqual(params, tt.wantParams) {
+				if err != nil {
+					t.Error(err)
+				}
+				if query != tt.wantQuery {
+					t.Errorf("Doesn't Match\nexpected: \n%s\n but got: \n%s\n", tt.wantQuery, query)
+				}
+				if !interfaceSliceEqual(params, tt.wantParams) {
+					t.Errorf("Doesn't Match\nexpected: \n%v\n but got: \n%v\n", tt.wantParams, params)
+				}
+			}
+		})
+	}
+}
+
+func TestEvalForAbnormal(t *testing.T) {
+	tests := []struct {
+		name      string
+		input     string
+		wantError string
+	}{
+		{
+			name:      "no END",
+			input:     `SELECT * FROM persons WHERE /* FOR m IN members */ first_name = /*m.name*/'Tim'`,
+			wantError: "can not parse: expected /* END */, but got 7",
+		},
+		{
+			name:      "ELSE in FOR",
+			input:     `SELECT * FROM persons WHERE /* FOR m IN members */ first_name = /*m.name*/'Tim' /* ELSE */ /* END */`,
+			wantError: "can not parse: expected /* END */, but got 4",
+		},
+		{
+			name:      "invalid FOR",
+			input:     `SELECT * FROM persons WHERE /* FOR members */ first_name = /*m.name*/'Tim' /* END */`,
+			wantError: `can not parse FOR "members": expected "item IN items" or "item IN items SEPARATOR ','"`,
+		},
+		{
+			name:      "unknown source",
+			input:     `SELECT * FROM persons WHERE /* FOR m IN people */ first_name = /*m.name*/'Tim' /* END */`,
+			wantError: "no parameter that matches the FOR value: people",
+		},
+		{
+			name:      "not slice",
+			input:     `SELECT * FROM persons WHERE /* FOR m IN deptNo */ first_name = /*m.name*/'Tim' /* END */`,
+			wantError: "FOR value must be a slice or an array: deptNo is int",
+		},
+		{
+			name:      "unknown field",
+			input:     `SELECT * FROM persons WHERE /* FOR m IN members */ first_name = /*m.first_name*/'Tim' /* END */`,
+			wantError: "no parameter that matches the bind value: m.first_name (m has no field first_name)",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			params := LoopInfo{Members: []Member{{Name: "Jeff"}}, DeptNo: 1}
+			if _, _, err := Eval(tt.input, &params); err == nil || err.Error() != tt.wantError {
+				if err == nil {
+					t.Errorf("should return error")
+				} else {
+					t.Errorf("\nexpected:\n%v\nbut got\n%v\n", tt.wantError, err.Error())
+				}
+			}
+		})
+	}
+}
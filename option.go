@@ -0,0 +1,89 @@
+package twowaysql
+
+// Option configures the behavior of Twowaysql, Compile and Eval.
+type Option func(*options)
+
+type options struct {
+	evaluator       ConditionEvaluator
+	allowed         map[string][]string
+	emptySlice      EmptySliceMode
+	placeholder     Placeholder
+	placeholderFunc func(n int, value interface{}) string
+	strictGet       bool
+	retry           *RetryPolicy
+	panicAsError    bool
+	loader          *Loader
+	registry        *Registry
+}
+
+func newOptions(opts []Option) *options {
+	o := &options{
+		evaluator: nativeEvaluator{},
+	}
+	for _, opt := range opts {
+		opt(o)
+	}
+	return o
+}
+
+// WithConditionEvaluator replaces the evaluator of /* IF ... */ and /* ELIF ... */ conditions.
+func WithConditionEvaluator(evaluator ConditionEvaluator) Option {
+	return func(o *options) {
+		o.evaluator = evaluator
+	}
+}
+
+// WithAllowedValues restricts the values embedded by /*$name*/ and /*#name*/ to the given list.
+// name is the parameter name without $ or #.
+// Values such as "NULLS LAST" that do not fit the default grammar can be embedded by listing them.
+func WithAllowedValues(name string, values ...string) Option {
+	return func(o *options) {
+		if o.allowed == nil {
+			o.allowed = map[string][]string{}
+		}
+		o.allowed[name] = append(o.allowed[name], values...)
+	}
+}
+
+// EmptySliceMode decides how an empty slice bound to an IN list is written.
+type EmptySliceMode int
+
+const (
+	// EmptySliceNull writes (NULL). "x IN (NULL)" matches no rows.
+	// Note that "x NOT IN (NULL)" matches no rows either.
+	EmptySliceNull EmptySliceMode = iota
+	// EmptySliceError makes Eval return an error.
+	EmptySliceError
+)
+
+// WithEmptySlice sets how an empty slice bound to an IN list is written. The default is EmptySliceNull.
+func WithEmptySlice(mode EmptySliceMode) Option {
+	return func(o *options) {
+		o.emptySlice = mode
+	}
+}
+
+// WithPlaceholder sets the style of the placeholders written by Eval.
+// Twowaysql chooses the style from the driver name of *sqlx.DB unless this option is given.
+func WithPlaceholder(placeholder Placeholder) Option {
+	return func(o *options) {
+		o.placeholder = placeholder
+	}
+}
+
+// WithPlaceholderFunc makes Eval write the placeholder of the n-th bind value, starting at 1, with fn
+// instead of the style given by WithPlaceholder. The bind values are returned as usual.
+// It is meant for databases with other placeholder styles and for tools writing the values into the query.
+func WithPlaceholderFunc(fn func(n int, value interface{}) string) Option {
+	return func(o *options) {
+		o.placeholderFunc = fn
+	}
+}
+
+// WithStrictGet makes Get return ErrTooManyRows when the query returns more than one row.
+// Without it, Get reads the first row and ignores the rest like sqlx.
+func WithStrictGet() Option {
+	return func(o *options) {
+		o.strictGet = true
+	}
+}
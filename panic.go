@@ -0,0 +1,71 @@
+package twowaysql
+
+import (
+	"errors"
+	"fmt"
+	"runtime/debug"
+)
+
+// PanicError is returned by Transaction when the function panics and WithPanicAsError is given.
+type PanicError struct {
+	// Value is the value passed to panic.
+	Value interface{}
+	// Stack is the stack trace of the goroutine at the time of the panic.
+	Stack []byte
+}
+
+func (e *PanicError) Error() string {
+	return fmt.Sprintf("twowaysql: panic in transaction: %v", e.Value)
+}
+
+// Unwrap returns Value if it is an error.
+func (e *PanicError) Unwrap() error {
+	err, _ := e.Value.(error)
+	return err
+}
+
+// WithPanicAsError makes Transaction return a *PanicError instead of re-panicking
+// when the function panics. The transaction is rolled back in either case.
+// Without it, when the rollback fails too, Transaction panics with an error
+// joining the *PanicError and the rollback error instead of the original value.
+func WithPanicAsError() Option {
+	return func(o *options) {
+		o.panicAsError = true
+	}
+}
+
+// runTxはトランザクションの中でfnを実行する
+// fnがエラーを返すかパニックを起こした場合はrollbackを呼ぶ
+// パニックはrollbackの後に再び起こすか、panicAsErrorの場合は*PanicErrorにして返す
+// rollbackにも失敗した場合はerrors.Joinで両方のエラーを返す。再びパニックを起こす場合も両方をまとめた値にする
+func runTx(fn func() error, rollback func() error, panicAsError bool) (err error) {
+	defer func() {
+		p := recover()
+		if p == nil {
+			return
+		}
+		perr := &PanicError{Value: p, Stack: debug.Stack()}
+		rerr := rollback()
+		switch {
+		case panicAsError:
+			err = joinRollbackError(perr, rerr)
+		case rerr != nil:
+			panic(joinRollbackError(perr, rerr))
+		default:
+			panic(p)
+		}
+	}()
+
+	if err := fn(); err != nil {
+		return joinRollbackError(err, rollback())
+	}
+	return nil
+}
+
+// joinRollbackErrorはrollbackに失敗した場合だけ二つのエラーをまとめる
+func joinRollbackError(err, rerr error) error {
+	if rerr == nil {
+		return err
+	}
+	return errors.Join(err, rerr)
+}
@@ -0,0 +1,120 @@
+package twowaysql
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestRunTx(t *testing.T) {
+	errFn := errors.New("fn failed")
+	errRollback := errors.New("rollback failed")
+
+	tests := []struct {
+		name         string
+		fn           func() error
+		rollbackErr  error
+		panicAsError bool
+		wantRollback bool
+		wantErrors   []error
+		wantPanic    bool
+	}{
+		{
+			name: "success",
+			fn:   func() error { return nil },
+		},
+		{
+			name:         "error",
+			fn:           func() error { return errFn },
+			wantRollback: true,
+			wantErrors:   []error{errFn},
+		},
+		{
+			name:         "error and rollback error",
+			fn:           func() error { return errFn },
+			rollbackErr:  errRollback,
+			wantRollback: true,
+			wantErrors:   []error{errFn, errRollback},
+		},
+		{
+			name:         "panic",
+			fn:           func() error { panic("boom") },
+			wantRollback: true,
+			wantPanic:    true,
+		},
+		{
+			name:         "panic and rollback error",
+			fn:           func() error { panic(errFn) },
+			rollbackErr:  errRollback,
+			wantRollback: true,
+			wantPanic:    true,
+			wantErrors:   []error{errFn, errRollback},
+		},
+		{
+			name:         "panic as error",
+			fn:           func() error { panic(errFn) },
+			panicAsError: true,
+			wantRollback: true,
+			wantErrors:   []error{errFn},
+		},
+		{
+			name:         "panic as error and rollback error",
+			fn:           func() error { panic("boom") },
+			rollbackErr:  errRollback,
+			panicAsError: true,
+			wantRollback: true,
+			wantErrors:   []error{errRollback},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rolledBack := false
+			rollback := func() error {
+				rolledBack = true
+				return tt.rollbackErr
+			}
+
+			var err error
+			panicked := func() (panicked bool) {
+				defer func() {
+					if p := recover(); p != nil {
+						panicked = true
+						// 再び起こしたパニックの値もエラーとして確かめる
+						err, _ = p.(error)
+					}
+				}()
+				err = runTx(tt.fn, rollback, tt.panicAsError)
+				return false
+			}()
+
+			if panicked != tt.wantPanic {
+				t.Errorf("panic: Doesn't Match\nexpected: %v\n but got: %v\n", tt.wantPanic, panicked)
+			}
+			if rolledBack != tt.wantRollback {
+				t.Errorf("rollback: Doesn't Match\nexpected: %v\n but got: %v\n", tt.wantRollback, rolledBack)
+			}
+			if len(tt.wantErrors) == 0 && err != nil {
+				t.Errorf("unexpected error: %v", err)
+			}
+			for _, want := range tt.wantErrors {
+				if !errors.Is(err, want) {
+					t.Errorf("\nexpected:\n%v\nbut got\n%v\n", want, err)
+				}
+			}
+			if tt.panicAsError || tt.wantPanic && tt.rollbackErr != nil {
+				var perr *PanicError
+				if !errors.As(err, &perr) || len(perr.Stack) == 0 {
+					t.Errorf("should return *PanicError: %v", err)
+				}
+			}
+		})
+	}
+}
+
+func TestRunTxKeepsError(t *testing.T) {
+	// rollbackに成功した場合はエラーをそのまま返す
+	errFn := errors.New("fn failed")
+	if err := runTx(func() error { return errFn }, func() error { return nil }, false); err != errFn {
+		t.Errorf("\nexpected:\n%v\nbut got\n%v\n", errFn, err)
+	}
+}
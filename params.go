@@ -0,0 +1,193 @@
+package twowaysql
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// ParamKind tells how a template uses a parameter.
+type ParamKind int
+
+const (
+	// ParamBind is a bind value such as /*maxEmpNo*/1000.
+	ParamBind ParamKind = iota + 1
+	// ParamEmbed is an embedded value such as /*$sortColumn*/.
+	ParamEmbed
+	// ParamCondition is a variable in the condition of IF or ELIF.
+	ParamCondition
+	// ParamLoop is the source of a FOR loop.
+	ParamLoop
+)
+
+func (k ParamKind) String() string {
+	switch k {
+	case ParamBind:
+		return "bind"
+	case ParamEmbed:
+		return "embed"
+	case ParamCondition:
+		return "condition"
+	case ParamLoop:
+		return "loop"
+	}
+	return fmt.Sprintf("ParamKind(%d)", int(k))
+}
+
+// Param is a parameter referenced by a template.
+type Param struct {
+	// Path is the reference as written, such as "maxEmpNo" or "dept.no".
+	Path string
+	Kind ParamKind
+	// Sample is the literal written after a bind, such as 1000, 'Tim' or (3, 5, 7).
+	// For a condition, it is the literal the variable is compared with, if any.
+	Sample string
+	// Elem lists the references to the loop variable in the body of a FOR loop.
+	// Their Path is relative to the element, and is empty when the element itself is referenced.
+	Elem []Param
+}
+
+// Params returns the parameters referenced by the template in order of appearance.
+// A parameter used several times in the same way is reported once.
+// References to loop variables are reported in Elem of the FOR source.
+// Condition variables are reported only with the native condition evaluator.
+func (t *Template) Params() []Param {
+	var params []Param
+	collectParams(t.tree, nil, &params)
+	return params
+}
+
+// loopVarはFORの中で参照できるループ変数と、その参照を集めるParamを表す
+type loopVar struct {
+	name  string
+	param *[]Param
+}
+
+// collectParamsは木を辿りながらパラメータの参照を集める
+// ループ変数への参照はそのFORのElemに加える
+func collectParams(node *tree, loops []loopVar, dest *[]Param) {
+	if node == nil {
+		return
+	}
+	switch node.Kind {
+	case ndBind:
+		addParam(loops, dest, Param{Path: node.Token.value, Kind: ParamBind, Sample: strings.TrimSpace(node.Token.literal)})
+	case ndEmbed:
+		addParam(loops, dest, Param{Path: strings.TrimLeft(node.Token.value, "$#"), Kind: ParamEmbed})
+	case ndIf, ndElif:
+		if cond, ok := node.cond.(*condition); ok {
+			conditionParams(cond.root, loops, dest)
+		}
+	case ndFor:
+		l := node.loop
+		if l == nil {
+			var err error
+			if l, err = parseLoop(node.Token.condition); err != nil {
+				break
+			}
+		}
+		loopParam := Param{Path: l.source, Kind: ParamLoop}
+		if addParam(loops, dest, loopParam) == nil {
+			break
+		}
+		// ループの中身だけループ変数を参照できる
+		// 中身を辿る間にdestが伸びて場所が変わるので、参照は別に集めてから加える
+		inner := append(append([]loopVar{}, loops...), loopVar{name: l.name, param: &loopParam.Elem})
+		collectParams(node.Left, inner, dest)
+		addParam(loops, dest, loopParam)
+		collectParams(node.Right, loops, dest)
+		return
+	}
+	collectParams(node.Left, loops, dest)
+	collectParams(node.Right, loops, dest)
+}
+
+// addParamはpがループ変数を参照していればそのFORのElemに、そうでなければdestに加える
+// 同じ参照が既にあればそれを返す。ループ変数の_indexなどは値が決まっているので返さない
+func addParam(loops []loopVar, dest *[]Param, p Param) *Param {
+	names := strings.SplitN(p.Path, ".", 2)
+	for i := len(loops) - 1; i >= 0; i-- {
+		l := loops[i]
+		switch names[0] {
+		case l.name:
+			p.Path = ""
+			if len(names) > 1 {
+				p.Path = names[1]
+			}
+			dest = l.param
+		case l.name + "_index", l.name + "_first", l.name + "_last":
+			return nil
+		default:
+			continue
+		}
+		break
+	}
+
+	return mergeParam(dest, p)
+}
+
+// mergeParamは同じ参照があればSampleとElemをまとめ、なければ加える
+func mergeParam(dest *[]Param, p Param) *Param {
+	for i := range *dest {
+		q := &(*dest)[i]
+		if q.Path != p.Path || q.Kind != p.Kind {
+			continue
+		}
+		if q.Sample == "" {
+			q.Sample = p.Sample
+		}
+		for _, elem := range p.Elem {
+			mergeParam(&q.Elem, elem)
+		}
+		return q
+	}
+	*dest = append(*dest, p)
+	return &(*dest)[len(*dest)-1]
+}
+
+// conditionParamsは条件式で参照している変数を集める
+// 変数がリテラルと比較されていればそのリテラルをSampleにする
+func conditionParams(node exprNode, loops []loopVar, dest *[]Param) {
+	switch e := node.(type) {
+	case *varExpr:
+		addParam(loops, dest, Param{Path: strings.Join(e.path, "."), Kind: ParamCondition})
+	case *binaryExpr:
+		if e.op != "in" {
+			if v, ok := e.x.(*varExpr); ok {
+				if lit, ok := e.y.(*literalExpr); ok {
+					addParam(loops, dest, Param{Path: strings.Join(v.path, "."), Kind: ParamCondition, Sample: formatLiteral(lit.value)})
+				}
+			}
+			if v, ok := e.y.(*varExpr); ok {
+				if lit, ok := e.x.(*literalExpr); ok {
+					addParam(loops, dest, Param{Path: strings.Join(v.path, "."), Kind: ParamCondition, Sample: formatLiteral(lit.value)})
+				}
+			}
+		}
+		conditionParams(e.x, loops, dest)
+		conditionParams(e.y, loops, dest)
+	case *logicalExpr:
+		conditionParams(e.x, loops, dest)
+		conditionParams(e.y, loops, dest)
+	case *unaryExpr:
+		conditionParams(e.x, loops, dest)
+	case *callExpr:
+		conditionParams(e.arg, loops, dest)
+	case *fieldExpr:
+		conditionParams(e.x, loops, dest)
+	case *listExpr:
+		for _, elem := range e.elems {
+			conditionParams(elem, loops, dest)
+		}
+	}
+}
+
+func formatLiteral(v interface{}) string {
+	switch v := v.(type) {
+	case nil:
+		return "null"
+	case string:
+		return strconv.Quote(v)
+	}
+	return fmt.Sprint(v)
+}
@@ -0,0 +1,82 @@
+package twowaysql
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestTemplateParams(t *testing.T) {
+	tests := []struct {
+		name  string
+		input string
+		want  []Param
+	}{
+		{
+			name:  "bind",
+			input: `SELECT * FROM person WHERE employee_no < /*maxEmpNo*/1000 AND first_name = /*firstName*/'Tim' AND dept_no IN /*deptNos*/(3, 5, 7)`,
+			want: []Param{
+				{Path: "maxEmpNo", Kind: ParamBind, Sample: "1000"},
+				{Path: "firstName", Kind: ParamBind, Sample: "'Tim'"},
+				{Path: "deptNos", Kind: ParamBind, Sample: "(3, 5, 7)"},
+			},
+		},
+		{
+			name:  "condition",
+			input: `SELECT * FROM person WHERE employee_no < /*maxEmpNo*/1000 /* IF deptNo > 0 && !retired */ AND dept_no = /*deptNo*/1 /* ELIF "HR" === dept.name */ AND dept_no = 0 /* END */`,
+			want: []Param{
+				{Path: "maxEmpNo", Kind: ParamBind, Sample: "1000"},
+				{Path: "deptNo", Kind: ParamCondition, Sample: "0"},
+				{Path: "retired", Kind: ParamCondition},
+				{Path: "deptNo", Kind: ParamBind, Sample: "1"},
+				{Path: "dept.name", Kind: ParamCondition, Sample: `"HR"`},
+			},
+		},
+		{
+			name:  "embed",
+			input: `SELECT * FROM person ORDER BY /*$sortColumn*/employee_no LIMIT /*#limit*/10 OFFSET /*offset*/0 /* IF offset */ /* END */`,
+			want: []Param{
+				{Path: "sortColumn", Kind: ParamEmbed},
+				{Path: "limit", Kind: ParamEmbed},
+				{Path: "offset", Kind: ParamBind, Sample: "0"},
+				{Path: "offset", Kind: ParamCondition},
+			},
+		},
+		{
+			name:  "loop",
+			input: `INSERT INTO persons (first_name, dept_no) VALUES /* FOR m IN members SEPARATOR ',' */(/*m.name*/'Tim', /*deptNo*/1 /* IF m_first */ /* END */)/* END */ /* FOR no IN dept.nos */ /*no*/1 /* END */`,
+			want: []Param{
+				{Path: "members", Kind: ParamLoop, Elem: []Param{
+					{Path: "name", Kind: ParamBind, Sample: "'Tim'"},
+				}},
+				{Path: "deptNo", Kind: ParamBind, Sample: "1"},
+				{Path: "dept.nos", Kind: ParamLoop, Elem: []Param{
+					{Path: "", Kind: ParamBind, Sample: "1"},
+				}},
+			},
+		},
+		{
+			name:  "nested loop",
+			input: `/* FOR d IN depts */ /*d.no*/1 /* FOR p IN d.persons */ /*p.name*/'Tim' /*d.no*/1 /* END */ /* END */`,
+			want: []Param{
+				{Path: "depts", Kind: ParamLoop, Elem: []Param{
+					{Path: "no", Kind: ParamBind, Sample: "1"},
+					{Path: "persons", Kind: ParamLoop, Elem: []Param{
+						{Path: "name", Kind: ParamBind, Sample: "'Tim'"},
+					}},
+				}},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tmpl, err := Compile(tt.input)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := tmpl.Params(); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Doesn't Match\nexpected: \n%+v\n but got: \n%+v\n", tt.want, got)
+			}
+		})
+	}
+}
@@ -1,78 +1,136 @@
 package twowaysql
 
 import (
-	"github.com/robertkrimen/otto"
+	"strings"
 )
 
 // 抽象構文木からトークン列を生成
 // 左部分木、右部分木と辿る
-// 現状右部分木を持つのはif, elif, elseだけ?
+// 現状右部分木を持つのはif, elif, else, forだけ
 func (t *tree) parse(params map[string]interface{}) ([]token, error) {
-	return  genInner(t, params)
-}
-
-func genInner(node *tree, params map[string]interface{}) ([]token, error) {
-	if node == nil {
-		return []token{}, nil
-	}
-
-	// 行きがけ
-
-	// 左部分木に行く
-	leftStr, err := genInner(node.Left, params)
-	if err != nil {
+	tokens := []token{}
+	if err := genInner(t, &scope{vars: params}, &tokens); err != nil {
 		return []token{}, err
 	}
+	return tokens, nil
+}
 
-	// 左部分木から戻ってきた
-
-	// 右部分木に行く
-	rightStr, err := genInner(node.Right, params)
-	if err != nil {
-		return []token{}, err
+// genInnerは木を辿りながら出力するトークンをdestに追加していく
+// 木は共有されるのでここでは書き換えない
+func genInner(node *tree, sc *scope, dest *[]token) error {
+	for node != nil {
+		switch kind := node.Kind; kind {
+		case ndSQLStmt:
+			*dest = append(*dest, *node.Token)
+			node = node.Left
+		case ndBind, ndEmbed:
+			tok := *node.Token
+			if sc.parent != nil {
+				// FORの中の変数はループ変数を参照できるようにscopeを覚えておく
+				tok.scope = sc
+			}
+			*dest = append(*dest, tok)
+			node = node.Left
+		case ndIf, ndElif:
+			truth, err := node.evalCondition(sc.params())
+			if err != nil {
+				return err
+			}
+			if !truth {
+				// 次のELIF, ELSE, ENDに進む
+				node = node.Right
+				continue
+			}
+			if err := genInner(node.Left, sc, dest); err != nil {
+				return err
+			}
+			// ENDの後ろに続く部分を出力する
+			node = endOf(node).Left
+		case ndElse:
+			if err := genInner(node.Left, sc, dest); err != nil {
+				return err
+			}
+			node = node.Right
+		case ndFor:
+			if err := node.genLoop(sc, dest); err != nil {
+				return err
+			}
+			node = node.Right
+		default:
+			// ndEnd, ndEndOfProgram
+			node = node.Left
+		}
 	}
+	return nil
+}
 
-	// 右部分木から戻ってきた
-	// 何を返すか
-	// 基本的に左部分木
-	// If Elifの場合は条件次第
-	switch kind := node.Kind; kind {
-	case ndSQLStmt, ndBind:
-		// めちゃめちゃ実行効率悪い気が...
-		return append([]token{*node.Token}, leftStr...), nil
-	case ndIf, ndElif:
-		truth, err := evalCondition(node.Token.condition, params)
-		if err != nil {
-			return []token{}, err
-		}
-		if truth {
-			return leftStr, nil
-		}
-		return rightStr, nil
-	default:
-		return leftStr, nil
+// IF, ELIF, ELSEに対応するENDのノードを返す
+func endOf(node *tree) *tree {
+	for node.Kind != ndEnd {
+		node = node.Right
 	}
+	return node
 }
 
 // /* If ... */ /* Elif ... */の条件を評価する
-func evalCondition(condition string, params map[string]interface{}) (bool, error) {
-	vm := otto.New()
-	for key, value := range params {
-		err := vm.Set(key, value)
+func (t *tree) evalCondition(params map[string]interface{}) (bool, error) {
+	cond := t.cond
+	if cond == nil {
+		// Compileを経由せずに組み立てた木では都度コンパイルする
+		var err error
+		cond, err = compileCondition(t.Token.condition)
 		if err != nil {
 			return false, err
 		}
 	}
+	return cond.Evaluate(params)
+}
 
-	result, err := vm.Run(condition)
-	if err != nil {
-		return false, err
+// /* FOR item IN items */の中身を要素の数だけ出力する
+func (t *tree) genLoop(sc *scope, dest *[]token) error {
+	l := t.loop
+	if l == nil {
+		// Compileを経由せずに組み立てた木では都度解析する
+		var err error
+		l, err = parseLoop(t.Token.condition)
+		if err != nil {
+			return err
+		}
 	}
 
-	truth, err := result.ToBoolean()
+	items, err := l.items(sc)
 	if err != nil {
-		return false, err
+		return err
 	}
+	emitted := false
+	for i := 0; i < items.Len(); i++ {
+		start := len(*dest)
+		if err := genInner(t.Left, l.enter(sc, items, i), dest); err != nil {
+			return err
+		}
+		// 何も出力しなかった要素の前後には区切り文字を入れない
+		if isBlank((*dest)[start:]) {
+			*dest = (*dest)[:start]
+			continue
+		}
+		if emitted && l.separator != "" {
+			body := append([]token{}, (*dest)[start:]...)
+			*dest = append(append((*dest)[:start], token{
+				kind: tkSQLStmt,
+				str:  l.separator,
+			}), body...)
+		}
+		emitted = true
+	}
+	return nil
+}
 
-	return truth, nil
+// isBlankはトークン列が空白しか出力しないかを返す
+func isBlank(tokens []token) bool {
+	for _, tok := range tokens {
+		if tok.kind != tkSQLStmt || strings.TrimSpace(tok.str) != "" {
+			return false
+		}
+	}
+	return true
 }
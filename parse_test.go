@@ -88,6 +88,10 @@ func TestParse(t *testing.T) {
 					kind: tkSQLStmt,
 					str:  " AND id=3 ",
 				},
+				{
+					kind: tkSQLStmt,
+					str:  " ",
+				},
 			},
 		},
 	}
@@ -0,0 +1,131 @@
+package twowaysql
+
+import (
+	"database/sql"
+	"strconv"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/jmoiron/sqlx"
+)
+
+// Placeholder is the style of the placeholders written for bind variables.
+type Placeholder int
+
+const (
+	// PlaceholderQuestion writes ?, used by MySQL and SQLite. It is the default.
+	PlaceholderQuestion Placeholder = iota + 1
+	// PlaceholderDollar writes $1, $2, ..., used by PostgreSQL.
+	PlaceholderDollar
+	// PlaceholderColon writes :1, :2, ..., used by Oracle.
+	PlaceholderColon
+	// PlaceholderAt writes @p1, @p2, ..., used by SQL Server.
+	PlaceholderAt
+	// PlaceholderNamed writes :name with the name of the bind variable.
+	// The bind values are returned as sql.NamedArg.
+	PlaceholderNamed
+)
+
+// placeholderOfはsqlxのドライバ名に合ったプレースホルダを返す
+func placeholderOf(driverName string) Placeholder {
+	// godrorは今のsqlxでは判定できない
+	if driverName == "godror" {
+		return PlaceholderColon
+	}
+	switch sqlx.BindType(driverName) {
+	case sqlx.DOLLAR:
+		return PlaceholderDollar
+	case sqlx.NAMED:
+		return PlaceholderColon
+	case sqlx.AT:
+		return PlaceholderAt
+	}
+	return PlaceholderQuestion
+}
+
+// binderはプレースホルダを書き出しながらバインドする値を集める
+type binder struct {
+	style    Placeholder
+	args     []interface{}
+	names    map[string]bool
+	reserved map[string]bool /* names of the bind variables in the query */
+	fn       func(n int, value interface{}) string
+}
+
+func newBinder(style Placeholder, capacity int, fn func(n int, value interface{}) string) *binder {
+	return &binder{
+		style: style,
+		args:  make([]interface{}, 0, capacity),
+		fn:    fn,
+	}
+}
+
+// reserveはクエリに書かれたバインド変数の名前を予約し、番号を付けた名前や.を_にした名前と衝突しないようにする
+func (b *binder) reserve(tokens []token) {
+	if b.style != PlaceholderNamed {
+		return
+	}
+	b.reserved = map[string]bool{}
+	for _, tok := range tokens {
+		if tok.kind == tkBind {
+			b.reserved[tok.value] = true
+		}
+	}
+}
+
+// bindは値を追加してプレースホルダを返す
+func (b *binder) bind(name string, value interface{}) string {
+	if b.style == PlaceholderNamed {
+		name = b.uniqueName(name)
+		b.args = append(b.args, sql.Named(name, value))
+		if b.fn != nil {
+			return b.fn(len(b.args), value)
+		}
+		return ":" + name
+	}
+
+	b.args = append(b.args, value)
+	if b.fn != nil {
+		return b.fn(len(b.args), value)
+	}
+	n := strconv.Itoa(len(b.args))
+	switch b.style {
+	case PlaceholderDollar:
+		return "$" + n
+	case PlaceholderColon:
+		return ":" + n
+	case PlaceholderAt:
+		return "@p" + n
+	}
+	return "?"
+}
+
+// uniqueNameはバインド変数の名前をプレースホルダに使える形にする
+// FORやIN句で同じ名前が何度も現れるので、二回目以降はname_2, name_3のように番号を付ける
+// _xや1stのように文字で始まらない名前はp_x, p1stのようにする
+// 書き換えた名前は、クエリに書かれた他のバインド変数の名前を避ける
+func (b *binder) uniqueName(original string) string {
+	name := strings.Map(func(r rune) rune {
+		if isIdentRune(r) {
+			return r
+		}
+		return '_'
+	}, original)
+	if r, _ := utf8.DecodeRuneInString(name); !unicode.IsLetter(r) {
+		// sql.Namedの名前は文字で始まらなければならない
+		name = "p" + name
+	}
+	if b.names == nil {
+		b.names = map[string]bool{}
+	}
+	taken := func(name string) bool {
+		return b.names[name] || name != original && b.reserved[name]
+	}
+	unique := name
+	for i := 2; taken(unique); i++ {
+		unique = name + "_" + strconv.Itoa(i)
+	}
+	b.names[unique] = true
+	return unique
+}
@@ -0,0 +1,205 @@
+package twowaysql
+
+import (
+	"database/sql"
+	"fmt"
+	"testing"
+)
+
+func TestEvalPlaceholder(t *testing.T) {
+	input := `SELECT * FROM person WHERE employee_no < /*maxEmpNo*/1000 AND gender IN /*gender_list*/('M') /* IF deptNo */ AND dept_no < /*deptNo*/1 /* END */`
+	params := Info{MaxEmpNo: 3, DeptNo: 12, GenderList: []string{"M", "F"}}
+
+	tests := []struct {
+		name        string
+		placeholder Placeholder
+		wantQuery   string
+		wantParams  []interface{}
+	}{
+		{
+			name:        "question",
+			placeholder: PlaceholderQuestion,
+			wantQuery:   `SELECT * FROM person WHERE employee_no < ?/*maxEmpNo*/ AND gender IN (?, ?)/*gender_list*/ AND dept_no < ?/*deptNo*/`,
+			wantParams:  []interface{}{3, "M", "F", 12},
+		},
+		{
+			name:        "dollar",
+			placeholder: PlaceholderDollar,
+			wantQuery:   `SELECT * FROM person WHERE employee_no < $1/*maxEmpNo*/ AND gender IN ($2, $3)/*gender_list*/ AND dept_no < $4/*deptNo*/`,
+			wantParams:  []interface{}{3, "M", "F", 12},
+		},
+		{
+			name:        "colon",
+			placeholder: PlaceholderColon,
+			wantQuery:   `SELECT * FROM person WHERE employee_no < :1/*maxEmpNo*/ AND gender IN (:2, :3)/*gender_list*/ AND dept_no < :4/*deptNo*/`,
+			wantParams:  []interface{}{3, "M", "F", 12},
+		},
+		{
+			name:        "at",
+			placeholder: PlaceholderAt,
+			wantQuery:   `SELECT * FROM person WHERE employee_no < @p1/*maxEmpNo*/ AND gender IN (@p2, @p3)/*gender_list*/ AND dept_no < @p4/*deptNo*/`,
+			wantParams:  []interface{}{3, "M", "F", 12},
+		},
+		{
+			name:        "named",
+			placeholder: PlaceholderNamed,
+			wantQuery:   `SELECT * FROM person WHERE employee_no < :maxEmpNo/*maxEmpNo*/ AND gender IN (:gender_list, :gender_list_2)/*gender_list*/ AND dept_no < :deptNo/*deptNo*/`,
+			wantParams:  []interface{}{sql.Named("maxEmpNo", 3), sql.Named("gender_list", "M"), sql.Named("gender_list_2", "F"), sql.Named("deptNo", 12)},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if query, bindParams, err := Eval(input, &params, WithPlaceholder(tt.placeholder)); err != nil || query != tt.wantQuery || !interfaceSliceEqual(bindParams, tt.wantParams) {
+				if err != nil {
+					t.Error(err)
+				}
+				if query != tt.wantQuery {
+					t.Errorf("Doesn't Match\nexpected: \n%s\n but got: \n%s\n", tt.wantQuery, query)
+				}
+				if !interfaceSliceEqual(bindParams, tt.wantParams) {
+					t.Errorf("Doesn't Match\nexpected: \n%v\n but got: \n%v\n", tt.wantParams, bindParams)
+				}
+			}
+		})
+	}
+}
+
+func TestEvalNamedPlaceholderInLoop(t *testing.T) {
+	input := `INSERT INTO persons (first_name, age) VALUES /* FOR m IN members SEPARATOR ',' */(/*m.name*/'Tim', /*m.age*/1)/* END */`
+	params := LoopInfo{Members: []Member{{Name: "Jeff", Age: 30}, {Name: "Tim", Age: 40}}}
+
+	wantQuery := `INSERT INTO persons (first_name, age) VALUES (:m_name/*m.name*/, :m_age/*m.age*/),(:m_name_2/*m.name*/, :m_age_2/*m.age*/)`
+	wantParams := []interface{}{sql.Named("m_name", "Jeff"), sql.Named("m_age", 30), sql.Named("m_name_2", "Tim"), sql.Named("m_age_2", 40)}
+
+	query, bindParams, err := Eval(input, &params, WithPlaceholder(PlaceholderNamed))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if query != wantQuery {
+		t.Errorf("Doesn't Match\nexpected: \n%s\n but got: \n%s\n", wantQuery, query)
+	}
+	if !interfaceSliceEqual(bindParams, wantParams) {
+		t.Errorf("Doesn't Match\nexpected: \n%v\n but got: \n%v\n", wantParams, bindParams)
+	}
+}
+
+func TestPlaceholderOf(t *testing.T) {
+	tests := []struct {
+		driverName string
+		want       Placeholder
+	}{
+		{driverName: "postgres", want: PlaceholderDollar},
+		{driverName: "pgx", want: PlaceholderDollar},
+		{driverName: "mysql", want: PlaceholderQuestion},
+		{driverName: "sqlite3", want: PlaceholderQuestion},
+		{driverName: "oci8", want: PlaceholderColon},
+		{driverName: "godror", want: PlaceholderColon},
+		{driverName: "sqlserver", want: PlaceholderAt},
+		{driverName: "unknown", want: PlaceholderQuestion},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.driverName, func(t *testing.T) {
+			if got := placeholderOf(tt.driverName); got != tt.want {
+				t.Errorf("Doesn't Match\nexpected: %v\n but got: %v\n", tt.want, got)
+			}
+		})
+	}
+}
+
+func TestEvalNamed(t *testing.T) {
+	params := NestedInfo{Filter: Filter{Dept: &Dept{No: 10, Name: "Sales"}}}
+
+	query, args, err := EvalNamed(`SELECT * FROM person WHERE dept_no = /*filter.dept.no*/1 OR dept_name = /*filter.dept.name*/'' OR parent_no = /*filter.dept.no*/1`, &params)
+	if err != nil {
+		t.Fatal(err)
+	}
+	wantQuery := `SELECT * FROM person WHERE dept_no = :filter_dept_no/*filter.dept.no*/ OR dept_name = :filter_dept_name/*filter.dept.name*/ OR parent_no = :filter_dept_no_2/*filter.dept.no*/`
+	wantArgs := []sql.NamedArg{sql.Named("filter_dept_no", 10), sql.Named("filter_dept_name", "Sales"), sql.Named("filter_dept_no_2", 10)}
+	if query != wantQuery {
+		t.Errorf("Doesn't Match\nexpected: \n%s\n but got: \n%s\n", wantQuery, query)
+	}
+	if len(args) != len(wantArgs) {
+		t.Fatalf("Doesn't Match\nexpected: \n%v\n but got: \n%v\n", wantArgs, args)
+	}
+	for i := range args {
+		if args[i] != wantArgs[i] {
+			t.Errorf("Doesn't Match\nexpected: \n%v\n but got: \n%v\n", wantArgs, args)
+		}
+	}
+
+	if _, _, err := EvalNamed(`SELECT * FROM person WHERE dept_no = /*deptNo*/1`, &params); err == nil {
+		t.Error("should return error")
+	}
+}
+
+func TestEvalNamedCollision(t *testing.T) {
+	tests := []struct {
+		name        string
+		input       string
+		inputParams map[string]interface{}
+		wantQuery   string
+		wantArgs    []sql.NamedArg
+	}{
+		{
+			name:        "dotted name",
+			input:       `SELECT /*a.b*/1, /*a_b*/2`,
+			inputParams: map[string]interface{}{"a": map[string]interface{}{"b": 1}, "a_b": 2},
+			wantQuery:   `SELECT :a_b_2/*a.b*/, :a_b/*a_b*/`,
+			wantArgs:    []sql.NamedArg{sql.Named("a_b_2", 1), sql.Named("a_b", 2)},
+		},
+		{
+			name:        "not starting with a letter",
+			input:       `SELECT /*_x*/1, /*1st*/2, /*p_x*/3`,
+			inputParams: map[string]interface{}{"_x": 1, "1st": 2, "p_x": 3},
+			wantQuery:   `SELECT :p_x_2/*_x*/, :p1st/*1st*/, :p_x/*p_x*/`,
+			wantArgs:    []sql.NamedArg{sql.Named("p_x_2", 1), sql.Named("p1st", 2), sql.Named("p_x", 3)},
+		},
+		{
+			name:        "in list",
+			input:       `SELECT * FROM person WHERE dept_no IN /*xs*/(1, 2) AND employee_no = /*xs_2*/1`,
+			inputParams: map[string]interface{}{"xs": []int{3, 5}, "xs_2": 7},
+			wantQuery:   `SELECT * FROM person WHERE dept_no IN (:xs, :xs_3)/*xs*/ AND employee_no = :xs_2/*xs_2*/`,
+			wantArgs:    []sql.NamedArg{sql.Named("xs", 3), sql.Named("xs_3", 5), sql.Named("xs_2", 7)},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			query, args, err := EvalNamed(tt.input, tt.inputParams)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if query != tt.wantQuery {
+				t.Errorf("Doesn't Match\nexpected: \n%s\n but got: \n%s\n", tt.wantQuery, query)
+			}
+			if len(args) != len(tt.wantArgs) {
+				t.Fatalf("Doesn't Match\nexpected: \n%v\n but got: \n%v\n", tt.wantArgs, args)
+			}
+			for i := range args {
+				if args[i] != tt.wantArgs[i] {
+					t.Errorf("Doesn't Match\nexpected: \n%v\n but got: \n%v\n", tt.wantArgs, args)
+				}
+			}
+		})
+	}
+}
+
+func TestEvalPlaceholderFunc(t *testing.T) {
+	fn := func(n int, value interface{}) string {
+		return fmt.Sprintf("$%d::int", n)
+	}
+	query, params, err := Eval(`SELECT * FROM person WHERE dept_no IN /*int_list*/(1, 2) AND employee_no < /*maxEmpNo*/10`, &Info{IntList: []int{3, 5}, MaxEmpNo: 7}, WithPlaceholderFunc(fn))
+	if err != nil {
+		t.Fatal(err)
+	}
+	wantQuery := `SELECT * FROM person WHERE dept_no IN ($1::int, $2::int)/*int_list*/ AND employee_no < $3::int/*maxEmpNo*/`
+	wantParams := []interface{}{3, 5, 7}
+	if query != wantQuery {
+		t.Errorf("Doesn't Match\nexpected: \n%s\n but got: \n%s\n", wantQuery, query)
+	}
+	if !interfaceSliceEqual(params, wantParams) {
+		t.Errorf("Doesn't Match\nexpected: \n%v\n but got: \n%v\n", wantParams, params)
+	}
+}
@@ -0,0 +1,62 @@
+package twowaysql
+
+import (
+	"context"
+	"database/sql"
+	"iter"
+)
+
+// Querier is the set of methods shared by Twowaysql and TwowaysqlTx.
+// Accept a Querier to run the same code with or without a transaction.
+//
+//	func updateName(ctx context.Context, q twowaysql.Querier, params *Params) error {
+//		_, err := q.Exec(ctx, `UPDATE persons SET first_name = /*firstName*/'Jon' WHERE employee_no = /*EmpNo*/1`, params)
+//		return err
+//	}
+type Querier interface {
+	Select(ctx context.Context, dest interface{}, query string, params interface{}) error
+	Get(ctx context.Context, dest interface{}, query string, params interface{}) error
+	Query(ctx context.Context, query string, params interface{}) (*Rows, error)
+	Exec(ctx context.Context, query string, params interface{}) (sql.Result, error)
+}
+
+var (
+	_ Querier = (*Twowaysql)(nil)
+	_ Querier = (*TwowaysqlTx)(nil)
+)
+
+// SelectAs is a generic version of Select that returns the rows as a slice of T.
+//
+//	people, err := twowaysql.SelectAs[Person](ctx, tw, query, &params)
+func SelectAs[T any](ctx context.Context, q Querier, query string, params interface{}) ([]T, error) {
+	var dest []T
+	if err := q.Select(ctx, &dest, query, params); err != nil {
+		return nil, err
+	}
+	return dest, nil
+}
+
+// GetAs is a generic version of Get that returns the row as T.
+// It returns sql.ErrNoRows when no row matches.
+func GetAs[T any](ctx context.Context, q Querier, query string, params interface{}) (T, error) {
+	var dest T
+	if err := q.Get(ctx, &dest, query, params); err != nil {
+		var zero T
+		return zero, err
+	}
+	return dest, nil
+}
+
+// QueryIter returns an iterator over the rows of the query as T. See Iter.
+// The query is issued when the iteration starts. If it fails, the iterator yields the error once.
+func QueryIter[T any](ctx context.Context, q Querier, query string, params interface{}) iter.Seq2[T, error] {
+	return func(yield func(T, error) bool) {
+		rows, err := q.Query(ctx, query, params)
+		if err != nil {
+			var zero T
+			yield(zero, err)
+			return
+		}
+		Iter[T](rows)(yield)
+	}
+}
@@ -0,0 +1,97 @@
+package twowaysql
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"testing"
+)
+
+// stubQuerierはDBを使わずにQuerierの呼び出しを確認する
+type stubQuerier struct {
+	err   error
+	query string
+}
+
+func (s *stubQuerier) Select(ctx context.Context, dest interface{}, query string, params interface{}) error {
+	s.query = query
+	if s.err != nil {
+		return s.err
+	}
+	*dest.(*[]Person) = []Person{{FirstName: "Evan"}, {FirstName: "Malvina"}}
+	return nil
+}
+
+func (s *stubQuerier) Get(ctx context.Context, dest interface{}, query string, params interface{}) error {
+	s.query = query
+	if s.err != nil {
+		return s.err
+	}
+	*dest.(*int) = 3
+	return nil
+}
+
+func (s *stubQuerier) Exec(ctx context.Context, query string, params interface{}) (sql.Result, error) {
+	s.query = query
+	return nil, s.err
+}
+
+func (s *stubQuerier) Query(ctx context.Context, query string, params interface{}) (*Rows, error) {
+	s.query = query
+	return nil, s.err
+}
+
+func TestSelectAs(t *testing.T) {
+	ctx := context.Background()
+
+	people, err := SelectAs[Person](ctx, &stubQuerier{}, `SELECT * FROM persons`, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(people) != 2 || people[1].FirstName != "Malvina" {
+		t.Errorf("unexpected result: %v", people)
+	}
+
+	wantErr := errors.New("select failed")
+	if people, err := SelectAs[Person](ctx, &stubQuerier{err: wantErr}, `SELECT * FROM persons`, nil); err != wantErr || people != nil {
+		t.Errorf("\nexpected:\n%v\nbut got\n%v %v\n", wantErr, people, err)
+	}
+}
+
+func TestGetAs(t *testing.T) {
+	ctx := context.Background()
+
+	count, err := GetAs[int](ctx, &stubQuerier{}, `SELECT count(*) FROM persons`, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if count != 3 {
+		t.Errorf("Doesn't Match\nexpected: %v\n but got: %v\n", 3, count)
+	}
+
+	wantErr := errors.New("get failed")
+	if count, err := GetAs[int](ctx, &stubQuerier{err: wantErr}, `SELECT count(*) FROM persons`, nil); err != wantErr || count != 0 {
+		t.Errorf("\nexpected:\n%v\nbut got\n%v %v\n", wantErr, count, err)
+	}
+}
+
+func TestQueryIterError(t *testing.T) {
+	wantErr := errors.New("query failed")
+	q := &stubQuerier{err: wantErr}
+
+	seq := QueryIter[Person](context.Background(), q, `SELECT * FROM persons`, nil)
+	if q.query != "" {
+		t.Error("query should not be issued until the iteration starts")
+	}
+
+	count := 0
+	for _, err := range seq {
+		count++
+		if err != wantErr {
+			t.Errorf("\nexpected:\n%v\nbut got\n%v\n", wantErr, err)
+		}
+	}
+	if count != 1 {
+		t.Errorf("iterator should stop after an error: %d", count)
+	}
+}
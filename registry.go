@@ -0,0 +1,199 @@
+package twowaysql
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io/fs"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// ErrQueryNotFound is returned when a named query is not registered.
+var ErrQueryNotFound = errors.New("twowaysql: query not found")
+
+// ErrNoRegistry is returned by the *Named methods when no Registry is given with WithRegistry.
+var ErrNoRegistry = errors.New("twowaysql: no registry is set, use WithRegistry")
+
+// -- name: FindPersonsByDept
+var nameMarker = regexp.MustCompile(`^\s*--\s*name:\s*(\S*)\s*$`)
+
+// Registry holds templates by name.
+// Queries are added from files that group several statements, each starting with a `-- name: QueryName` line.
+//
+//	-- name: FindPersonsByDept
+//	SELECT * FROM persons WHERE dept_no = /*deptNo*/1
+//
+//	-- name: DeletePerson
+//	DELETE FROM persons WHERE employee_no = /*EmpNo*/1
+//
+// A Registry is safe for concurrent use.
+type Registry struct {
+	opts []Option
+
+	mu        sync.RWMutex
+	templates map[string]*Template
+	sources   map[string]string
+}
+
+// NewRegistry returns an empty Registry. opts are used to compile the templates.
+func NewRegistry(opts ...Option) *Registry {
+	return &Registry{
+		opts:      opts,
+		templates: map[string]*Template{},
+		sources:   map[string]string{},
+	}
+}
+
+// Add compiles query and registers it as name. It returns an error if name is already registered.
+func (r *Registry) Add(name, query string) error {
+	return r.add(map[string]string{name: query}, "Add")
+}
+
+// Parse splits src by the `-- name:` markers and registers every query.
+// source is the name of src used in error messages, such as the file name.
+// Nothing is registered if src has an error.
+func (r *Registry) Parse(source, src string) error {
+	queries, err := splitNamedQueries(source, src)
+	if err != nil {
+		return err
+	}
+	return r.add(queries, source)
+}
+
+// AddFS registers the queries of all files in fsys matching pattern. See fs.Glob for the pattern syntax.
+func (r *Registry) AddFS(fsys fs.FS, pattern string) error {
+	names, err := fs.Glob(fsys, pattern)
+	if err != nil {
+		return err
+	}
+	for _, name := range names {
+		src, err := fs.ReadFile(fsys, name)
+		if err != nil {
+			return fmt.Errorf("twowaysql: load %s: %w", name, err)
+		}
+		if err := r.Parse(name, string(src)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Lookup returns the template registered as name. It returns an error wrapping ErrQueryNotFound if there is none.
+func (r *Registry) Lookup(name string) (*Template, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	tmpl, ok := r.templates[name]
+	if !ok {
+		return nil, fmt.Errorf("%w: %s", ErrQueryNotFound, name)
+	}
+	return tmpl, nil
+}
+
+// Names returns the registered names in sorted order.
+func (r *Registry) Names() []string {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	names := make([]string, 0, len(r.templates))
+	for name := range r.templates {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// addはまとめてコンパイルし、すべて成功した場合だけ登録する
+func (r *Registry) add(queries map[string]string, source string) error {
+	templates := make(map[string]*Template, len(queries))
+	for name, query := range queries {
+		tmpl, err := Compile(query, r.opts...)
+		if err != nil {
+			return fmt.Errorf("twowaysql: compile %s in %s: %w", name, source, err)
+		}
+		templates[name] = tmpl
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for name := range templates {
+		if prev, ok := r.sources[name]; ok {
+			return fmt.Errorf("twowaysql: duplicate query name %s in %s, already defined in %s", name, source, prev)
+		}
+	}
+	for name, tmpl := range templates {
+		r.templates[name] = tmpl
+		r.sources[name] = source
+	}
+	return nil
+}
+
+// splitNamedQueriesは-- name:の行で区切ってクエリを取り出す
+// 最初の-- name:より前には空行とコメントしか書けない
+func splitNamedQueries(source, src string) (map[string]string, error) {
+	queries := map[string]string{}
+	var name string
+	var body strings.Builder
+	flush := func() error {
+		if name == "" {
+			return nil
+		}
+		query := strings.TrimSpace(removeLineComments(body.String()))
+		if query == "" {
+			return fmt.Errorf("twowaysql: query %s in %s is empty", name, source)
+		}
+		queries[name] = body.String()
+		body.Reset()
+		return nil
+	}
+
+	scanner := bufio.NewScanner(strings.NewReader(src))
+	scanner.Buffer(nil, len(src)+1)
+	for lineNo := 1; scanner.Scan(); lineNo++ {
+		line := scanner.Text()
+		if m := nameMarker.FindStringSubmatch(line); m != nil {
+			if err := flush(); err != nil {
+				return nil, err
+			}
+			name = m[1]
+			if name == "" {
+				return nil, fmt.Errorf("twowaysql: %s:%d: query name is empty", source, lineNo)
+			}
+			if _, ok := queries[name]; ok {
+				return nil, fmt.Errorf("twowaysql: %s:%d: duplicate query name %s", source, lineNo, name)
+			}
+			continue
+		}
+		if name == "" {
+			if strings.TrimSpace(removeLineComments(line)) != "" {
+				return nil, fmt.Errorf("twowaysql: %s:%d: query must start with a -- name: line", source, lineNo)
+			}
+			continue
+		}
+		body.WriteString(line)
+		body.WriteByte('\n')
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	if err := flush(); err != nil {
+		return nil, err
+	}
+	return queries, nil
+}
+
+// WithRegistry sets the Registry used by SelectNamed, GetNamed, QueryNamed and ExecNamed.
+func WithRegistry(registry *Registry) Option {
+	return func(o *options) {
+		o.registry = registry
+	}
+}
+
+// lookupはWithRegistryで指定されたRegistryからテンプレートを取り出す
+func (o *options) lookup(name string) (*Template, error) {
+	if o.registry == nil {
+		return nil, ErrNoRegistry
+	}
+	return o.registry.Lookup(name)
+}
@@ -0,0 +1,156 @@
+package twowaysql
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"testing"
+	"testing/fstest"
+
+	"github.com/jmoiron/sqlx"
+)
+
+func TestRegistry(t *testing.T) {
+	registry := NewRegistry()
+	if err := registry.AddFS(testSQL, "testdata/sql/named/*.sql"); err != nil {
+		t.Fatal(err)
+	}
+
+	wantNames := []string{"FindPersonsByDept", "UpdatePersonName"}
+	if names := registry.Names(); !reflect.DeepEqual(names, wantNames) {
+		t.Errorf("Doesn't Match\nexpected: \n%v\n but got: \n%v\n", wantNames, names)
+	}
+
+	tests := []struct {
+		name       string
+		params     Info
+		wantQuery  string
+		wantParams []interface{}
+	}{
+		{
+			name:       "FindPersonsByDept",
+			params:     Info{MaxEmpNo: 3, DeptNo: 12},
+			wantQuery:  `SELECT first_name, last_name, email FROM persons WHERE employee_no < ?/*maxEmpNo*/ AND dept_no < ?/*deptNo*/ ORDER BY employee_no`,
+			wantParams: []interface{}{3, 12},
+		},
+		{
+			name:       "UpdatePersonName",
+			params:     Info{EmpNo: 1, FirstName: "Jon"},
+			wantQuery:  `UPDATE persons SET first_name = ?/*firstName*/ WHERE employee_no = ?/*EmpNo*/`,
+			wantParams: []interface{}{"Jon", 1},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tmpl, err := registry.Lookup(tt.name)
+			if err != nil {
+				t.Fatal(err)
+			}
+			query, params, err := tmpl.Eval(&tt.params)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if query != tt.wantQuery {
+				t.Errorf("Doesn't Match\nexpected: \n%s\n but got: \n%s\n", tt.wantQuery, query)
+			}
+			if !interfaceSliceEqual(params, tt.wantParams) {
+				t.Errorf("Doesn't Match\nexpected: \n%v\n but got: \n%v\n", tt.wantParams, params)
+			}
+		})
+	}
+}
+
+func TestRegistryAbnormal(t *testing.T) {
+	tests := []struct {
+		name      string
+		src       string
+		wantError string
+	}{
+		{
+			name:      "no marker",
+			src:       "-- comment\nSELECT 1",
+			wantError: "twowaysql: a.sql:2: query must start with a -- name: line",
+		},
+		{
+			name:      "empty name",
+			src:       "-- name:\nSELECT 1",
+			wantError: "twowaysql: a.sql:1: query name is empty",
+		},
+		{
+			name:      "empty query",
+			src:       "-- name: A\n-- comment\n\n-- name: B\nSELECT 1",
+			wantError: "twowaysql: query A in a.sql is empty",
+		},
+		{
+			name:      "duplicate name in file",
+			src:       "-- name: A\nSELECT 1\n-- name: A\nSELECT 2",
+			wantError: "twowaysql: a.sql:3: duplicate query name A",
+		},
+		{
+			name:      "duplicate name in registry",
+			src:       "-- name: FindPersonsByDept\nSELECT 1",
+			wantError: "twowaysql: duplicate query name FindPersonsByDept in a.sql, already defined in testdata/sql/named/persons.sql",
+		},
+		{
+			name:      "compile error",
+			src:       "-- name: A\nSELECT * FROM persons /* IF deptNo */",
+			wantError: "twowaysql: compile A in a.sql: can not parse: expected /* END */, but got 7",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			registry := NewRegistry()
+			if err := registry.AddFS(testSQL, "testdata/sql/named/*.sql"); err != nil {
+				t.Fatal(err)
+			}
+			err := registry.Parse("a.sql", tt.src)
+			if err == nil {
+				t.Fatal("should return error")
+			}
+			if err.Error() != tt.wantError {
+				t.Errorf("\nexpected:\n%v\nbut got\n%v\n", tt.wantError, err.Error())
+			}
+			// エラーがあった場合は何も登録しない
+			if names := registry.Names(); len(names) != 2 {
+				t.Errorf("should not register any query: %v", names)
+			}
+		})
+	}
+}
+
+func TestRegistryLookup(t *testing.T) {
+	registry := NewRegistry()
+	if err := registry.AddFS(fstest.MapFS{
+		"a.sql": {Data: []byte("-- name: A\nSELECT 1")},
+	}, "*.sql"); err != nil {
+		t.Fatal(err)
+	}
+
+	_, err := registry.Lookup("B")
+	if !errors.Is(err, ErrQueryNotFound) {
+		t.Errorf("should return ErrQueryNotFound: %v", err)
+	}
+	wantError := "twowaysql: query not found: B"
+	if err.Error() != wantError {
+		t.Errorf("\nexpected:\n%v\nbut got\n%v\n", wantError, err.Error())
+	}
+
+	if err := registry.Add("A", "SELECT 2"); err == nil {
+		t.Error("should return error for duplicate name")
+	}
+}
+
+func TestNamedWithoutRegistry(t *testing.T) {
+	db, err := sqlx.Open("postgres", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+
+	var people []Person
+	if err := New(db).SelectNamed(context.Background(), &people, "FindPersonsByDept", nil); !errors.Is(err, ErrNoRegistry) {
+		t.Errorf("\nexpected:\n%v\nbut got\n%v\n", ErrNoRegistry, err)
+	}
+}
@@ -0,0 +1,142 @@
+package twowaysql
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"time"
+)
+
+// RetryPolicy makes Transaction roll back and run the function again with a fresh transaction
+// when it fails with a retryable error such as a serialization failure.
+type RetryPolicy struct {
+	// MaxAttempts is the number of times the function is run, including the first one.
+	// Values less than 2 disable retrying.
+	MaxAttempts int
+	// Backoff returns how long to wait before the given retry, which starts at 1. nil means no wait.
+	Backoff func(retry int) time.Duration
+	// Retryable reports whether err is worth retrying. nil means IsRetryable.
+	Retryable func(err error) bool
+}
+
+// WithRetryPolicy enables retrying of Transaction and TransactionWithOptions.
+// Nested transactions started by TwowaysqlTx.Transaction are not retried.
+func WithRetryPolicy(policy RetryPolicy) Option {
+	return func(o *options) {
+		o.retry = &policy
+	}
+}
+
+// ExponentialBackoff returns a backoff that doubles the wait from base on every retry, up to max.
+func ExponentialBackoff(base, max time.Duration) func(retry int) time.Duration {
+	return func(retry int) time.Duration {
+		d := base
+		for i := 1; i < retry && d < max; i++ {
+			d *= 2
+		}
+		if d > max {
+			d = max
+		}
+		return d
+	}
+}
+
+// IsRetryable reports whether err is a serialization failure or a deadlock
+// reported by lib/pq, pgx or go-sql-driver/mysql.
+func IsRetryable(err error) bool {
+	return IsRetryableSQLState(err) || IsMySQLDeadlock(err)
+}
+
+// IsRetryableSQLState reports whether err has SQLSTATE 40001 (serialization_failure) or 40P01 (deadlock_detected).
+// It understands *pq.Error of lib/pq without depending on it, and errors with a SQLState() string method
+// such as *pgconn.PgError of pgx.
+func IsRetryableSQLState(err error) bool {
+	return findError(err, func(err error) bool {
+		var code string
+		if e, ok := err.(interface{ SQLState() string }); ok {
+			code = e.SQLState()
+		} else {
+			code = pqErrorCode(err)
+		}
+		return code == "40001" || code == "40P01"
+	})
+}
+
+// pqErrorCodeは*pq.ErrorのCodeフィールドを返す。pq.Errorでなければ空にする
+// lib/pqをimportするとinitでドライバが登録されてしまうのでリフレクションで読む
+func pqErrorCode(err error) string {
+	rv := reflect.ValueOf(err)
+	if rv.Kind() == reflect.Ptr {
+		if rv.IsNil() {
+			return ""
+		}
+		rv = rv.Elem()
+	}
+	if rv.Kind() != reflect.Struct || rv.Type().Name() != "Error" || rv.Type().PkgPath() != "github.com/lib/pq" {
+		return ""
+	}
+	code := rv.FieldByName("Code")
+	if !code.IsValid() || code.Kind() != reflect.String {
+		return ""
+	}
+	return code.String()
+}
+
+// IsMySQLDeadlock reports whether err is a MySQL error 1213 (ER_LOCK_DEADLOCK).
+// It understands *mysql.MySQLError of go-sql-driver/mysql without depending on it.
+func IsMySQLDeadlock(err error) bool {
+	return findError(err, func(err error) bool {
+		// *mysql.MySQLErrorのNumberフィールドを見る。同じ名前の他のパッケージの型は対象にしない
+		rv := reflect.ValueOf(err)
+		if rv.Kind() != reflect.Ptr || rv.IsNil() || rv.Elem().Kind() != reflect.Struct ||
+			rv.Elem().Type().Name() != "MySQLError" || rv.Elem().Type().PkgPath() != "github.com/go-sql-driver/mysql" {
+			return false
+		}
+		number := rv.Elem().FieldByName("Number")
+		return number.IsValid() && number.Kind() == reflect.Uint16 && number.Uint() == 1213
+	})
+}
+
+// findErrorはラップされたエラーを辿ってmatchするものがあるかを返す
+func findError(err error, match func(error) bool) bool {
+	if err == nil {
+		return false
+	}
+	if match(err) {
+		return true
+	}
+	switch e := err.(type) {
+	case interface{ Unwrap() error }:
+		return findError(e.Unwrap(), match)
+	case interface{ Unwrap() []error }:
+		for _, err := range e.Unwrap() {
+			if findError(err, match) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// retryはポリシーに従ってfnを繰り返す
+func (p *RetryPolicy) retry(ctx context.Context, fn func() error) error {
+	retryable := p.Retryable
+	if retryable == nil {
+		retryable = IsRetryable
+	}
+	for attempt := 1; ; attempt++ {
+		err := fn()
+		if err == nil || attempt >= p.MaxAttempts || !retryable(err) {
+			return err
+		}
+		if p.Backoff != nil {
+			timer := time.NewTimer(p.Backoff(attempt))
+			select {
+			case <-ctx.Done():
+				timer.Stop()
+				return errors.Join(err, ctx.Err())
+			case <-timer.C:
+			}
+		}
+	}
+}
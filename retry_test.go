@@ -0,0 +1,145 @@
+package twowaysql
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/go-sql-driver/mysql"
+	"github.com/lib/pq"
+)
+
+// pgconn.PgErrorの代わり
+type sqlStateError struct {
+	code string
+}
+
+func (e *sqlStateError) Error() string    { return "sqlstate " + e.code }
+func (e *sqlStateError) SQLState() string { return e.code }
+
+// mysql.MySQLErrorと同じ名前の別の型
+type MySQLError struct {
+	Number  uint16
+	Message string
+}
+
+func (e *MySQLError) Error() string { return e.Message }
+
+func TestIsRetryable(t *testing.T) {
+	tests := []struct {
+		name string
+		err  error
+		want bool
+	}{
+		{name: "nil", err: nil, want: false},
+		{name: "other", err: errors.New("other"), want: false},
+		{name: "pq serialization failure", err: &pq.Error{Code: "40001"}, want: true},
+		{name: "pq deadlock", err: &pq.Error{Code: "40P01"}, want: true},
+		{name: "pq unique violation", err: &pq.Error{Code: "23505"}, want: false},
+		{name: "pq error value", err: pq.Error{Code: "40001"}, want: true},
+		{name: "pgx serialization failure", err: &sqlStateError{code: "40001"}, want: true},
+		{name: "pgx syntax error", err: &sqlStateError{code: "42601"}, want: false},
+		{name: "mysql deadlock", err: &mysql.MySQLError{Number: 1213}, want: true},
+		{name: "mysql duplicate entry", err: &mysql.MySQLError{Number: 1062}, want: false},
+		{name: "other MySQLError", err: &MySQLError{Number: 1213}, want: false},
+		{name: "wrapped", err: fmt.Errorf("update: %w", &pq.Error{Code: "40001"}), want: true},
+		{name: "joined", err: errors.Join(errors.New("other"), &mysql.MySQLError{Number: 1213}), want: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := IsRetryable(tt.err); got != tt.want {
+				t.Errorf("Doesn't Match\nexpected: %v\n but got: %v\n", tt.want, got)
+			}
+		})
+	}
+}
+
+func TestExponentialBackoff(t *testing.T) {
+	backoff := ExponentialBackoff(10*time.Millisecond, 50*time.Millisecond)
+	for retry, want := range []time.Duration{10 * time.Millisecond, 20 * time.Millisecond, 40 * time.Millisecond, 50 * time.Millisecond, 50 * time.Millisecond} {
+		if got := backoff(retry + 1); got != want {
+			t.Errorf("retry %d: Doesn't Match\nexpected: %v\n but got: %v\n", retry+1, want, got)
+		}
+	}
+}
+
+func TestRetryPolicy(t *testing.T) {
+	retryable := &pq.Error{Code: "40001"}
+	other := errors.New("other")
+
+	tests := []struct {
+		name         string
+		policy       RetryPolicy
+		errs         []error
+		wantAttempts int
+		wantError    error
+	}{
+		{
+			name:         "success after retry",
+			policy:       RetryPolicy{MaxAttempts: 3},
+			errs:         []error{retryable, retryable, nil},
+			wantAttempts: 3,
+		},
+		{
+			name:         "give up",
+			policy:       RetryPolicy{MaxAttempts: 2},
+			errs:         []error{retryable, retryable, nil},
+			wantAttempts: 2,
+			wantError:    retryable,
+		},
+		{
+			name:         "not retryable",
+			policy:       RetryPolicy{MaxAttempts: 3},
+			errs:         []error{other, nil},
+			wantAttempts: 1,
+			wantError:    other,
+		},
+		{
+			name: "custom classifier",
+			policy: RetryPolicy{MaxAttempts: 3, Retryable: func(err error) bool {
+				return err == other
+			}},
+			errs:         []error{other, nil},
+			wantAttempts: 2,
+		},
+		{
+			name:         "backoff",
+			policy:       RetryPolicy{MaxAttempts: 3, Backoff: ExponentialBackoff(time.Millisecond, time.Millisecond)},
+			errs:         []error{retryable, nil},
+			wantAttempts: 2,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			attempts := 0
+			err := tt.policy.retry(context.Background(), func() error {
+				attempts++
+				return tt.errs[attempts-1]
+			})
+			if err != tt.wantError {
+				t.Errorf("\nexpected:\n%v\nbut got\n%v\n", tt.wantError, err)
+			}
+			if attempts != tt.wantAttempts {
+				t.Errorf("Doesn't Match\nexpected: %v\n but got: %v\n", tt.wantAttempts, attempts)
+			}
+		})
+	}
+}
+
+func TestRetryPolicyCanceled(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	policy := RetryPolicy{MaxAttempts: 3, Backoff: func(int) time.Duration { return time.Hour }}
+	retryable := &pq.Error{Code: "40001"}
+
+	err := policy.retry(ctx, func() error {
+		cancel()
+		return retryable
+	})
+	if !errors.Is(err, context.Canceled) || !errors.Is(err, retryable) {
+		t.Errorf("unexpected error: %v", err)
+	}
+}
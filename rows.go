@@ -0,0 +1,95 @@
+package twowaysql
+
+import (
+	"iter"
+	"reflect"
+
+	"github.com/jmoiron/sqlx"
+)
+
+// Rows is a cursor over the result of Query. It is a thin wrapper around sqlx.Rows.
+// Rows must be closed after use unless it is consumed by Iter.
+type Rows struct {
+	rows *sqlx.Rows
+}
+
+// Next prepares the next row for reading. It returns false when there are no more rows or an error occurred.
+func (r *Rows) Next() bool {
+	return r.rows.Next()
+}
+
+// Scan copies the columns of the current row into dest like sql.Rows.Scan.
+func (r *Rows) Scan(dest ...interface{}) error {
+	return r.rows.Scan(dest...)
+}
+
+// StructScan copies the current row into a struct. The struct tag format must be `db:"tag_name"`.
+func (r *Rows) StructScan(dest interface{}) error {
+	return r.rows.StructScan(dest)
+}
+
+// MapScan copies the current row into a map keyed by column name.
+func (r *Rows) MapScan(dest map[string]interface{}) error {
+	return r.rows.MapScan(dest)
+}
+
+// Columns returns the column names.
+func (r *Rows) Columns() ([]string, error) {
+	return r.rows.Columns()
+}
+
+// Err returns the error encountered during iteration.
+func (r *Rows) Err() error {
+	return r.rows.Err()
+}
+
+// Close closes the rows. It is safe to call Close more than once.
+func (r *Rows) Close() error {
+	return r.rows.Close()
+}
+
+// Iter returns an iterator that reads every row of rows as T and closes rows at the end.
+// T is a struct with `db:"tag_name"` tags or a scannable type such as int or string.
+// On error the iterator yields the error once and stops.
+//
+//	rows, err := tw.Query(ctx, query, &params)
+//	if err != nil {
+//		return err
+//	}
+//	for person, err := range twowaysql.Iter[Person](rows) {
+//		if err != nil {
+//			return err
+//		}
+//		...
+//	}
+func Iter[T any](rows *Rows) iter.Seq2[T, error] {
+	return func(yield func(T, error) bool) {
+		defer rows.Close()
+
+		var zero T
+		scannable := isScannable(reflect.TypeOf(&zero))
+		for rows.Next() {
+			var v T
+			var err error
+			if scannable {
+				err = rows.Scan(&v)
+			} else {
+				err = rows.StructScan(&v)
+			}
+			if err != nil {
+				yield(zero, err)
+				return
+			}
+			if !yield(v, nil) {
+				return
+			}
+		}
+		if err := rows.Err(); err != nil {
+			yield(zero, err)
+			return
+		}
+		if err := rows.Close(); err != nil {
+			yield(zero, err)
+		}
+	}
+}
@@ -0,0 +1,99 @@
+package twowaysql
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+
+	"github.com/jmoiron/sqlx"
+)
+
+// ErrNestedTx is returned when Commit or Rollback is called on a transaction started by TwowaysqlTx.Transaction.
+// A nested transaction is committed or rolled back when its function returns.
+var ErrNestedTx = errors.New("twowaysql: can not commit or roll back a nested transaction directly")
+
+// savepointDialectはセーブポイントを操作するSQL文の書式
+// releaseが空の場合はRELEASEにあたる文を発行しない
+type savepointDialect struct {
+	save     string
+	release  string
+	rollback string
+}
+
+var (
+	// PostgreSQL, MySQL, SQLite
+	standardSavepoint = savepointDialect{
+		save:     "SAVEPOINT %s",
+		release:  "RELEASE SAVEPOINT %s",
+		rollback: "ROLLBACK TO SAVEPOINT %s",
+	}
+	oracleSavepoint = savepointDialect{
+		save:     "SAVEPOINT %s",
+		rollback: "ROLLBACK TO SAVEPOINT %s",
+	}
+	sqlServerSavepoint = savepointDialect{
+		save:     "SAVE TRANSACTION %s",
+		rollback: "ROLLBACK TRANSACTION %s",
+	}
+)
+
+// savepointDialectOfはsqlxのドライバ名に合ったセーブポイントの書式を返す
+func savepointDialectOf(driverName string) savepointDialect {
+	switch placeholderOf(driverName) {
+	case PlaceholderColon:
+		return oracleSavepoint
+	case PlaceholderAt:
+		return sqlServerSavepoint
+	}
+	return standardSavepoint
+}
+
+// savepointsは一つのトランザクションの中で使うセーブポイントの名前を払い出す
+type savepoints struct {
+	dialect savepointDialect
+	count   int
+}
+
+func newSavepoints(tx *sqlx.Tx) *savepoints {
+	return &savepoints{dialect: savepointDialectOf(tx.DriverName())}
+}
+
+func (s *savepoints) next() string {
+	s.count++
+	return "twowaysql_sp_" + strconv.Itoa(s.count)
+}
+
+// Transaction runs fn in a nested transaction using a savepoint.
+// If fn returns an error, only the work done in fn is rolled back and the error is returned;
+// the outer transaction stays usable. Otherwise the savepoint is released.
+// A panic in fn also rolls back to the savepoint, then is handled like in Twowaysql.Transaction.
+// The statements are chosen from the driver name: SAVEPOINT for PostgreSQL, MySQL and SQLite,
+// and SAVE TRANSACTION for SQL Server.
+func (t *TwowaysqlTx) Transaction(ctx context.Context, fn func(tx *TwowaysqlTx) error) error {
+	name := t.savepoints.next()
+	dialect := t.savepoints.dialect
+
+	if _, err := t.tx.ExecContext(ctx, fmt.Sprintf(dialect.save, name)); err != nil {
+		return fmt.Errorf("savepoint %s: %w", name, err)
+	}
+
+	nested := *t
+	nested.savepoint = name
+	rollback := func() error {
+		if _, err := t.tx.ExecContext(ctx, fmt.Sprintf(dialect.rollback, name)); err != nil {
+			return fmt.Errorf("rollback to savepoint %s: %w", name, err)
+		}
+		return nil
+	}
+	if err := runTx(func() error { return fn(&nested) }, rollback, t.o.panicAsError); err != nil {
+		return err
+	}
+
+	if dialect.release != "" {
+		if _, err := t.tx.ExecContext(ctx, fmt.Sprintf(dialect.release, name)); err != nil {
+			return fmt.Errorf("release savepoint %s: %w", name, err)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,35 @@
+package twowaysql
+
+import (
+	"testing"
+)
+
+func TestSavepointDialectOf(t *testing.T) {
+	tests := []struct {
+		driverName string
+		want       savepointDialect
+	}{
+		{driverName: "postgres", want: standardSavepoint},
+		{driverName: "mysql", want: standardSavepoint},
+		{driverName: "sqlite3", want: standardSavepoint},
+		{driverName: "godror", want: oracleSavepoint},
+		{driverName: "sqlserver", want: sqlServerSavepoint},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.driverName, func(t *testing.T) {
+			if got := savepointDialectOf(tt.driverName); got != tt.want {
+				t.Errorf("Doesn't Match\nexpected: %v\n but got: %v\n", tt.want, got)
+			}
+		})
+	}
+}
+
+func TestSavepointsNext(t *testing.T) {
+	sp := &savepoints{dialect: standardSavepoint}
+	for _, want := range []string{"twowaysql_sp_1", "twowaysql_sp_2", "twowaysql_sp_3"} {
+		if got := sp.next(); got != want {
+			t.Errorf("Doesn't Match\nexpected: %v\n but got: %v\n", want, got)
+		}
+	}
+}
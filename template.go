@@ -0,0 +1,111 @@
+package twowaysql
+
+import (
+	"database/sql"
+	"fmt"
+)
+
+// Template is a parsed 2WaySQL query.
+// A Template is immutable once compiled, so it can be shared between goroutines
+// and evaluated many times without parsing the query again.
+type Template struct {
+	query string
+	tree  *tree
+	opts  *options
+}
+
+// Compile parses a 2WaySQL query and returns a Template that can be evaluated repeatedly.
+func Compile(query string, opts ...Option) (*Template, error) {
+	o := newOptions(opts)
+
+	tokens, err := tokenize(formatQuery(query))
+	if err != nil {
+		return nil, err
+	}
+
+	tree, err := ast(tokens)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := tree.compile(o.evaluator); err != nil {
+		return nil, err
+	}
+
+	return &Template{
+		query: query,
+		tree:  tree,
+		opts:  o,
+	}, nil
+}
+
+// MustCompile is like Compile but panics if the query cannot be parsed.
+// It simplifies safe initialization of global variables holding templates.
+func MustCompile(query string, opts ...Option) *Template {
+	t, err := Compile(query, opts...)
+	if err != nil {
+		panic(fmt.Sprintf("twowaysql: Compile(%q): %v", query, err))
+	}
+	return t
+}
+
+// String returns the source query used to compile the template.
+func (t *Template) String() string {
+	return t.query
+}
+
+// Eval returns converted query and bind value.
+// inputParams takes a tagged struct or a map[string]interface{}. Tags must be in the form `twowaysql:"tag_name"`.
+// Only the conditions and binds are evaluated, the query is not parsed again.
+func (t *Template) Eval(inputParams interface{}) (string, []interface{}, error) {
+	style := t.opts.placeholder
+	if style == 0 {
+		style = PlaceholderQuestion
+	}
+	return t.eval(inputParams, style)
+}
+
+// EvalNamed returns converted query with :name placeholders and the bind values as sql.NamedArg.
+// It is the Template version of EvalNamed.
+func (t *Template) EvalNamed(inputParams interface{}) (string, []sql.NamedArg, error) {
+	query, params, err := t.eval(inputParams, PlaceholderNamed)
+	if err != nil {
+		return "", nil, err
+	}
+
+	args := make([]sql.NamedArg, len(params))
+	for i, param := range params {
+		args[i] = param.(sql.NamedArg)
+	}
+	return query, args, nil
+}
+
+// evalはプレースホルダの形式を指定して評価する
+func (t *Template) eval(inputParams interface{}, style Placeholder) (string, []interface{}, error) {
+	mapParams := map[string]interface{}{}
+
+	if inputParams != nil {
+		if err := encode(mapParams, inputParams); err != nil {
+			return "", nil, err
+		}
+	} else {
+		mapParams = nil
+	}
+
+	generatedTokens, err := t.tree.parse(mapParams)
+	if err != nil {
+		return "", nil, err
+	}
+
+	generatedTokens, err = trimClauses(generatedTokens)
+	if err != nil {
+		return "", nil, err
+	}
+
+	convertedQuery, params, err := build(generatedTokens, mapParams, t.opts, style)
+	if err != nil {
+		return "", nil, err
+	}
+
+	return arrangeWhiteSpace(convertedQuery), params, nil
+}
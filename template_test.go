@@ -0,0 +1,91 @@
+package twowaysql
+
+import (
+	"sync"
+	"testing"
+)
+
+func TestTemplateEval(t *testing.T) {
+	tmpl, err := Compile(`SELECT * FROM person WHERE employee_no < /*maxEmpNo*/1000 /* IF deptNo */ AND dept_no < /*deptNo*/1 /* END */ ORDER BY employee_no`)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name        string
+		inputParams Info
+		wantQuery   string
+		wantParams  []interface{}
+	}{
+		{
+			name:        "if true",
+			inputParams: Info{MaxEmpNo: 3, DeptNo: 12},
+			wantQuery:   `SELECT * FROM person WHERE employee_no < ?/*maxEmpNo*/ AND dept_no < ?/*deptNo*/ ORDER BY employee_no`,
+			wantParams:  []interface{}{3, 12},
+		},
+		{
+			name:        "if false",
+			inputParams: Info{MaxEmpNo: 5},
+			wantQuery:   `SELECT * FROM person WHERE employee_no < ?/*maxEmpNo*/ ORDER BY employee_no`,
+			wantParams:  []interface{}{5},
+		},
+	}
+
+	// 同じテンプレートを繰り返し評価しても結果が変わらないこと
+	for i := 0; i < 2; i++ {
+		for _, tt := range tests {
+			t.Run(tt.name, func(t *testing.T) {
+				query, params, err := tmpl.Eval(&tt.inputParams)
+				if err != nil {
+					t.Fatal(err)
+				}
+				if query != tt.wantQuery {
+					t.Errorf("Doesn't Match\nexpected: \n%s\n but got: \n%s\n", tt.wantQuery, query)
+				}
+				if !interfaceSliceEqual(params, tt.wantParams) {
+					t.Errorf("Doesn't Match\nexpected: \n%v\n but got: \n%v\n", tt.wantParams, params)
+				}
+			})
+		}
+	}
+}
+
+func TestTemplateConcurrentEval(t *testing.T) {
+	tmpl := MustCompile(`SELECT * FROM person WHERE employee_no < /*maxEmpNo*/1000 /* IF deptNo */ AND dept_no < /*deptNo*/1 /* END */`)
+
+	var wg sync.WaitGroup
+	for i := 0; i < 20; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			query, params, err := tmpl.Eval(&Info{MaxEmpNo: i, DeptNo: i})
+			if err != nil {
+				t.Error(err)
+				return
+			}
+			wantQuery := `SELECT * FROM person WHERE employee_no < ?/*maxEmpNo*/`
+			wantParams := []interface{}{i}
+			if i != 0 {
+				wantQuery += ` AND dept_no < ?/*deptNo*/`
+				wantParams = append(wantParams, i)
+			}
+			if query != wantQuery || !interfaceSliceEqual(params, wantParams) {
+				t.Errorf("Doesn't Match\nexpected: \n%s %v\n but got: \n%s %v\n", wantQuery, wantParams, query, params)
+			}
+		}(i)
+	}
+	wg.Wait()
+}
+
+func TestCompileAbnormal(t *testing.T) {
+	if _, err := Compile(`SELECT * FROM person WHERE employee_no < 1000 /* IF true */ AND dept_no = 1`); err == nil {
+		t.Error("should return error")
+	}
+
+	defer func() {
+		if r := recover(); r == nil {
+			t.Error("MustCompile should panic")
+		}
+	}()
+	MustCompile(`SELECT * FROM person /* END */`)
+}
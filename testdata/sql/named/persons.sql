@@ -0,0 +1,24 @@
+-- 社員に関するクエリ
+
+-- name: FindPersonsByDept
+SELECT
+	first_name,
+	last_name,
+	email
+FROM
+	persons
+WHERE
+	employee_no < /*maxEmpNo*/1000
+	/* IF deptNo */
+	AND dept_no < /*deptNo*/1
+	/* END */
+ORDER BY
+	employee_no
+
+-- name: UpdatePersonName
+UPDATE
+	persons
+SET
+	first_name = /*firstName*/'Jon'
+WHERE
+	employee_no = /*EmpNo*/1
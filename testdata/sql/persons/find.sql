@@ -0,0 +1,14 @@
+-- 部署番号で社員を検索する
+SELECT
+	first_name,
+	last_name,
+	email
+FROM
+	persons
+WHERE
+	employee_no < /*maxEmpNo*/1000
+	/* IF deptNo */
+	AND dept_no < /*deptNo*/1
+	/* END */
+ORDER BY
+	employee_no
@@ -0,0 +1,6 @@
+UPDATE
+	persons
+SET
+	first_name = /*firstName*/'Jon'
+WHERE
+	employee_no = /*EmpNo*/1
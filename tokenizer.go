@@ -16,13 +16,17 @@ const (
 	tkEnd
 	tkBind
 	tkEndOfProgram
+	tkFor
+	tkEmbed
 )
 
 type token struct {
 	kind      tokenKind
 	str       string
-	value     string /* for Bind */
-	condition string /* for IF/ELIF */
+	value     string /* for Bind, Embed */
+	literal   string /* for Bind, sample value following the comment */
+	condition string /* for IF/ELIF, FOR */
+	scope     *scope /* for Bind, Embed in FOR */
 }
 
 // tokenizeは文字列を受け取ってトークンの列を返す
@@ -44,28 +48,7 @@ func tokenize(str string) ([]token, error) {
 			})
 			start = index
 			index += 2
-			tok := token{}
 			for index < length && str[index:index+2] != "*/" {
-				if str[index:index+2] == "IF" {
-					tok.kind = tkIf
-					index += 2
-					continue
-				}
-				if str[index:index+4] == "ELIF" {
-					tok.kind = tkElif
-					index += 4
-					continue
-				}
-				if str[index:index+4] == "ELSE" {
-					tok.kind = tkElse
-					index += 4
-					continue
-				}
-				if str[index:index+3] == "END" {
-					tok.kind = tkEnd
-					index += 3
-					continue
-				}
 				index++
 			}
 			// */がなければ不正なフォーマット
@@ -73,8 +56,10 @@ func tokenize(str string) ([]token, error) {
 				return []token{}, errors.New("Comment enclosing characters do not match")
 			}
 			index += 2
-			if tok.kind == 0 {
-				tok.kind = tkBind
+			tok := token{
+				kind: directiveKind(str[start+2 : index-2]),
+			}
+			if tok.kind == tkBind || tok.kind == tkEmbed {
 				if quote := str[index]; quote == '(' {
 					// /* ... */( ... )
 					index++
@@ -107,14 +92,20 @@ func tokenize(str string) ([]token, error) {
 
 			tok.str = str[start:index]
 			switch tok.kind {
-			case tkIf, tkElif:
+			case tkIf, tkElif, tkFor:
 				tok.condition = retrieveCondition(tok.kind, tok.str)
 			case tkBind:
+				tok.literal = tok.str[len(removeLiteral(tok.str)):]
 				tok.str = bindLiteral(tok.str)
 				tok.value = retrieveValue(tok.str)
+			case tkEmbed:
+				tok.str = removeLiteral(tok.str)
+				tok.value = retrieveValue(tok.str)
 			}
 			start = index
 			tokens = append(tokens, tok)
+			// 直後に別のコメントが続く場合があるので読み飛ばさない
+			continue
 		}
 		if index == length-1 {
 			tokens = append(tokens, token{
@@ -132,6 +123,38 @@ func tokenize(str string) ([]token, error) {
 	return tokens, nil
 }
 
+// directiveKindはコメントの先頭の単語からトークンの種類を判定する
+// $または#で始まればEmbed、IF, ELIF, ELSE, END, FORのいずれでもなければBindとみなす
+func directiveKind(comment string) tokenKind {
+	comment = strings.TrimLeft(comment, " ")
+	if strings.HasPrefix(comment, "$") || strings.HasPrefix(comment, "#") {
+		return tkEmbed
+	}
+	for _, directive := range []struct {
+		keyword string
+		kind    tokenKind
+	}{
+		{"IF", tkIf},
+		{"ELIF", tkElif},
+		{"ELSE", tkElse},
+		{"END", tkEnd},
+		{"FOR", tkFor},
+	} {
+		if !strings.HasPrefix(comment, directive.keyword) {
+			continue
+		}
+		rest := comment[len(directive.keyword):]
+		if rest == "" || !isIdentRune(rune(rest[0])) {
+			return directive.kind
+		}
+	}
+	return tkBind
+}
+
+func isIdentRune(r rune) bool {
+	return r == '_' || unicode.IsLetter(r) || unicode.IsDigit(r)
+}
+
 // ?/*value*/から value1を取り出す
 func retrieveValue(str string) string {
 	retStr := strings.Trim(str, " ")
@@ -142,14 +165,18 @@ func retrieveValue(str string) string {
 
 // /*value*/1000 -> ?/*value*/ みたいに変換する
 func bindLiteral(str string) string {
-	str = strings.TrimRightFunc(str, func(r rune) bool {
+	return "?" + removeLiteral(str)
+}
+
+// /*$value*/employee_no -> /*$value*/ みたいに変換する
+func removeLiteral(str string) string {
+	return strings.TrimRightFunc(str, func(r rune) bool {
 		return r != unicode.SimpleFold('/')
 	})
-	return "?" + str
 }
 
-// /* (IF|ELIF) condition */ -> conditionを返す
-// kind must be tkIf or tkElif
+// /* (IF|ELIF|FOR) condition */ -> conditionを返す
+// kind must be tkIf, tkElif or tkFor
 func retrieveCondition(kind tokenKind, str string) string {
 	str = removeCommentSymbol(str)
 	str = strings.Trim(str, " ")
@@ -158,8 +185,10 @@ func retrieveCondition(kind tokenKind, str string) string {
 		str = strings.TrimPrefix(str, "IF")
 	case tkElif:
 		str = strings.TrimPrefix(str, "ELIF")
+	case tkFor:
+		str = strings.TrimPrefix(str, "FOR")
 	default:
-		panic("kind must be tKIF or tkElif")
+		panic("kind must be tKIF, tkElif or tkFor")
 	}
 	return strings.TrimLeft(str, " ")
 }
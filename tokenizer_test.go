@@ -41,9 +41,10 @@ func TestTokenize(t *testing.T) {
 					str:  `SELECT * FROM person WHERE first_name = `,
 				},
 				{
-					kind:  tkBind,
-					str:   "?/* firstName */",
-					value: "firstName",
+					kind:    tkBind,
+					str:     "?/* firstName */",
+					value:   "firstName",
+					literal: `"Jeff Dean"`,
 				},
 				{
 					kind: tkEndOfProgram,
@@ -59,9 +60,10 @@ func TestTokenize(t *testing.T) {
 					str:  `SELECT * FROM person WHERE first_name = `,
 				},
 				{
-					kind:  tkBind,
-					str:   "?/* firstName */",
-					value: "firstName",
+					kind:    tkBind,
+					str:     "?/* firstName */",
+					value:   "firstName",
+					literal: `'Jeff Dean'`,
 				},
 				{
 					kind: tkEndOfProgram,
@@ -77,9 +79,10 @@ func TestTokenize(t *testing.T) {
 					str:  `SELECT * FROM person WHERE first_name = `,
 				},
 				{
-					kind:  tkBind,
-					str:   "?/* firstName */",
-					value: "firstName",
+					kind:    tkBind,
+					str:     "?/* firstName */",
+					value:   "firstName",
+					literal: `"Jeff Dean"`,
 				},
 				{
 					kind: tkSQLStmt,
@@ -99,18 +102,20 @@ func TestTokenize(t *testing.T) {
 					str:  "INSERT INTO persons (employee_no, dept_no, first_name, last_name, email) VALUES(",
 				},
 				{
-					kind:  tkBind,
-					str:   "?/*EmpNo*/",
-					value: "EmpNo",
+					kind:    tkBind,
+					str:     "?/*EmpNo*/",
+					value:   "EmpNo",
+					literal: "1",
 				},
 				{
 					kind: tkSQLStmt,
 					str:  ", ",
 				},
 				{
-					kind:  tkBind,
-					str:   "?/*deptNo*/",
-					value: "deptNo",
+					kind:    tkBind,
+					str:     "?/*deptNo*/",
+					value:   "deptNo",
+					literal: "1",
 				},
 				{
 					kind: tkSQLStmt,
@@ -156,9 +161,10 @@ func TestTokenize(t *testing.T) {
 					str:  "SELECT * FROM person WHERE employee_no < ",
 				},
 				{
-					kind:  tkBind,
-					str:   "?/*maxEmpNo*/",
-					value: "maxEmpNo",
+					kind:    tkBind,
+					str:     "?/*maxEmpNo*/",
+					value:   "maxEmpNo",
+					literal: "1000",
 				},
 				{
 					kind: tkSQLStmt,
@@ -174,9 +180,10 @@ func TestTokenize(t *testing.T) {
 					str:  " AND dept_no = ",
 				},
 				{
-					kind:  tkBind,
-					str:   "?/*deptNo*/",
-					value: "deptNo",
+					kind:    tkBind,
+					str:     "?/*deptNo*/",
+					value:   "deptNo",
+					literal: "1",
 				},
 				{
 					kind: tkSQLStmt,
@@ -311,9 +318,10 @@ func TestTokenize(t *testing.T) {
 					str:  " WHERE person.gender in ",
 				},
 				{
-					kind:  tkBind,
-					str:   "?/*gender_list*/",
-					value: "gender_list",
+					kind:    tkBind,
+					str:     "?/*gender_list*/",
+					value:   "gender_list",
+					literal: `('M')`,
 				},
 				{
 					kind: tkSQLStmt,
@@ -328,6 +336,141 @@ func TestTokenize(t *testing.T) {
 				},
 			},
 		},
+		{
+			name:  "for",
+			input: `INSERT INTO persons (first_name) VALUES /* FOR m IN members SEPARATOR ',' */(/*m.name*/'Tim')/* END */`,
+			want: []token{
+				{
+					kind: tkSQLStmt,
+					str:  "INSERT INTO persons (first_name) VALUES ",
+				},
+				{
+					kind:      tkFor,
+					str:       "/* FOR m IN members SEPARATOR ',' */",
+					condition: "m IN members SEPARATOR ','",
+				},
+				{
+					kind: tkSQLStmt,
+					str:  "(",
+				},
+				{
+					kind:    tkBind,
+					str:     "?/*m.name*/",
+					value:   "m.name",
+					literal: `'Tim'`,
+				},
+				{
+					kind: tkSQLStmt,
+					str:  ")",
+				},
+				{
+					kind: tkEnd,
+					str:  "/* END */",
+				},
+				{
+					kind: tkEndOfProgram,
+				},
+			},
+		},
+		{
+			name:  "bind starts with keyword",
+			input: `SELECT * FROM person WHERE end_date < /*ENDDATE*/'2000-01-01' AND format = /* FORMAT */1`,
+			want: []token{
+				{
+					kind: tkSQLStmt,
+					str:  "SELECT * FROM person WHERE end_date < ",
+				},
+				{
+					kind:    tkBind,
+					str:     "?/*ENDDATE*/",
+					value:   "ENDDATE",
+					literal: `'2000-01-01'`,
+				},
+				{
+					kind: tkSQLStmt,
+					str:  " AND format = ",
+				},
+				{
+					kind:    tkBind,
+					str:     "?/* FORMAT */",
+					value:   "FORMAT",
+					literal: "1",
+				},
+				{
+					kind: tkEndOfProgram,
+				},
+			},
+		},
+		{
+			name:  "embed",
+			input: `SELECT * FROM person ORDER BY /*$sortColumn*/employee_no /* #direction */ASC`,
+			want: []token{
+				{
+					kind: tkSQLStmt,
+					str:  "SELECT * FROM person ORDER BY ",
+				},
+				{
+					kind:  tkEmbed,
+					str:   "/*$sortColumn*/",
+					value: "$sortColumn",
+				},
+				{
+					kind: tkSQLStmt,
+					str:  " ",
+				},
+				{
+					kind:  tkEmbed,
+					str:   "/* #direction */",
+					value: "#direction",
+				},
+				{
+					kind: tkEndOfProgram,
+				},
+			},
+		},
+		{
+			name:  "adjacent comments",
+			input: `/* IF true *//* IF false */a/* END *//* END */`,
+			want: []token{
+				{
+					kind: tkSQLStmt,
+					str:  "",
+				},
+				{
+					kind:      tkIf,
+					str:       "/* IF true */",
+					condition: "true",
+				},
+				{
+					kind: tkSQLStmt,
+					str:  "",
+				},
+				{
+					kind:      tkIf,
+					str:       "/* IF false */",
+					condition: "false",
+				},
+				{
+					kind: tkSQLStmt,
+					str:  "a",
+				},
+				{
+					kind: tkEnd,
+					str:  "/* END */",
+				},
+				{
+					kind: tkSQLStmt,
+					str:  "",
+				},
+				{
+					kind: tkEnd,
+					str:  "/* END */",
+				},
+				{
+					kind: tkEndOfProgram,
+				},
+			},
+		},
 	}
 
 	for _, tt := range tests {
@@ -0,0 +1,272 @@
+package twowaysql
+
+import (
+	"errors"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// ErrEmptyWhere is returned when the WHERE clause of an UPDATE or DELETE statement
+// becomes empty because all of its conditional blocks were skipped.
+// The WHERE is not removed, so the statement never affects all rows by accident.
+var ErrEmptyWhere = errors.New("twowaysql: WHERE clause of UPDATE or DELETE is empty after evaluating the conditions")
+
+// trimClausesは条件分岐を評価した結果、宙に浮いたキーワードを取り除く
+//
+//	SELECT * FROM person WHERE ORDER BY id      -> SELECT * FROM person ORDER BY id
+//	SELECT * FROM person WHERE AND dept_no = 1  -> SELECT * FROM person WHERE dept_no = 1
+//	UPDATE person SET name = 'a', WHERE id = 1  -> UPDATE person SET name = 'a' WHERE id = 1
+//	SELECT id, name, FROM person                -> SELECT id, name FROM person
+//	SELECT * FROM person WHERE () AND id = 1    -> SELECT * FROM person WHERE id = 1
+//
+// バインド変数のトークンは取り除かず、SQL文のトークンだけを書き換える
+// UPDATE文とDELETE文のWHEREは全行が対象になってしまうので取り除かずにエラーにする
+func trimClauses(tokens []token) ([]token, error) {
+	lexemes := lexTokens(tokens)
+
+	for {
+		// 取り除くたびに前後関係が変わるので最初から見直す
+		sig := significant(lexemes)
+		removed := false
+		for i, idx := range sig {
+			if shouldTrim(lexemes, sig, i) {
+				if lexemes[idx].isWord("WHERE") && modifiesRows(lexemes, sig, i) {
+					return nil, ErrEmptyWhere
+				}
+				remove(lexemes, idx)
+				if lexemes[idx].isSymbol("(") {
+					// 空の括弧は閉じ括弧もあわせて取り除く
+					remove(lexemes, sig[i+1])
+				}
+				removed = true
+				break
+			}
+		}
+		if !removed {
+			break
+		}
+	}
+
+	// SQL文のトークンを組み立て直す
+	result := make([]token, len(tokens))
+	copy(result, tokens)
+	var b strings.Builder
+	for i := range result {
+		if result[i].kind != tkSQLStmt {
+			continue
+		}
+		b.Reset()
+		for _, l := range lexemes {
+			if l.tok == i && !l.removed {
+				b.WriteString(l.str)
+			}
+		}
+		result[i].str = b.String()
+	}
+	return result, nil
+}
+
+type lexemeKind int
+
+const (
+	lxSpace lexemeKind = iota + 1
+	lxWord
+	lxSymbol
+	lxQuoted
+	lxBind
+)
+
+type lexeme struct {
+	tok     int /* index of token */
+	kind    lexemeKind
+	str     string
+	removed bool
+}
+
+// shouldTrimはsig[i]番目の語が宙に浮いているかを返す
+func shouldTrim(lexemes []lexeme, sig []int, i int) bool {
+	l := &lexemes[sig[i]]
+	var prev, next *lexeme
+	if i > 0 {
+		prev = &lexemes[sig[i-1]]
+	}
+	if i < len(sig)-1 {
+		next = &lexemes[sig[i+1]]
+	}
+	// 後ろに何も続かない
+	dangling := next == nil || next.isSymbol(")") || next.isSymbol(";") || startsClause(lexemes, sig, i+1)
+
+	switch {
+	case l.isSymbol("("):
+		// 条件が空になった括弧。関数呼び出しのcount()などは残す
+		return next != nil && next.isSymbol(")") &&
+			(prev == nil || prev.isSymbol("(") || prev.isWord("WHERE") || prev.isWord("HAVING") ||
+				prev.isWord("AND") || prev.isWord("OR") || prev.isWord("ON"))
+	case l.isWord("WHERE") || l.isWord("HAVING"):
+		return dangling
+	case l.isWord("AND") || l.isWord("OR"):
+		return dangling || prev == nil || prev.isSymbol("(") || prev.isWord("WHERE") || prev.isWord("HAVING")
+	case l.isSymbol(","):
+		return dangling || prev == nil || prev.isSymbol("(") || prev.isSymbol(",") ||
+			prev.isWord("SELECT") || prev.isWord("SET") || prev.isWord("BY")
+	}
+	return false
+}
+
+// modifiesRowsはsig[i]番目の語がUPDATE文かDELETE文の句であるかを返す
+// 括弧の中の副問い合わせの句は含めない
+func modifiesRows(lexemes []lexeme, sig []int, i int) bool {
+	depth := 0
+	for j := i - 1; j >= 0; j-- {
+		l := &lexemes[sig[j]]
+		switch {
+		case l.isSymbol(")"):
+			depth++
+		case l.isSymbol("("):
+			if depth == 0 {
+				return false
+			}
+			depth--
+		case depth > 0:
+		case l.isSymbol(";"):
+			return false
+		case l.isWord("UPDATE") || l.isWord("DELETE"):
+			return true
+		}
+	}
+	return false
+}
+
+// startsClauseはsig[i]番目から次の句が始まるかを返す
+// 列名にも使われる語は後ろに続く語も見て判断する
+func startsClause(lexemes []lexeme, sig []int, i int) bool {
+	if i >= len(sig) || lexemes[sig[i]].kind != lxWord {
+		return false
+	}
+	var next, second *lexeme
+	if i+1 < len(sig) {
+		next = &lexemes[sig[i+1]]
+	}
+	if i+2 < len(sig) {
+		second = &lexemes[sig[i+2]]
+	}
+
+	switch strings.ToUpper(lexemes[sig[i]].str) {
+	case "FROM", "WHERE", "HAVING", "UNION", "EXCEPT", "INTERSECT":
+		return true
+	case "ORDER", "GROUP":
+		return next != nil && next.isWord("BY")
+	case "LIMIT", "OFFSET":
+		return next != nil && (next.kind == lxBind || next.isWord("ALL") || next.kind == lxWord && unicode.IsDigit(rune(next.str[0])))
+	case "RETURNING":
+		return next != nil && (next.isSymbol("*") || next.kind == lxWord && !next.isWord("FROM"))
+	case "FETCH":
+		return next != nil && (next.isWord("FIRST") || next.isWord("NEXT"))
+	case "FOR":
+		return next != nil && (next.isWord("UPDATE") || next.isWord("SHARE") || next.isWord("NO") || next.isWord("KEY"))
+	case "WINDOW":
+		return next != nil && next.kind == lxWord && second != nil && second.isWord("AS")
+	}
+	return false
+}
+
+// removeは語を取り除く。直前の空白もあわせて取り除く
+// 括弧の内側に接する場合は直後の空白も取り除く
+func remove(lexemes []lexeme, idx int) {
+	lexemes[idx].removed = true
+	prev := neighbor(lexemes, idx, -1)
+	if prev >= 0 && lexemes[prev].kind == lxSpace {
+		lexemes[prev].removed = true
+		prev = neighbor(lexemes, prev, -1)
+	}
+	next := neighbor(lexemes, idx, 1)
+	if next < 0 || lexemes[next].kind != lxSpace {
+		return
+	}
+	following := neighbor(lexemes, next, 1)
+	if prev >= 0 && lexemes[prev].isSymbol("(") || following >= 0 && (lexemes[following].isSymbol(")") || lexemes[following].isSymbol(";")) {
+		lexemes[next].removed = true
+	}
+}
+
+// neighborはidxから見てdirの方向にある取り除かれていない要素の位置を返す
+func neighbor(lexemes []lexeme, idx, dir int) int {
+	for i := idx + dir; i >= 0 && i < len(lexemes); i += dir {
+		if !lexemes[i].removed {
+			return i
+		}
+	}
+	return -1
+}
+
+func significant(lexemes []lexeme) []int {
+	sig := make([]int, 0, len(lexemes))
+	for i, l := range lexemes {
+		if !l.removed && l.kind != lxSpace {
+			sig = append(sig, i)
+		}
+	}
+	return sig
+}
+
+func (l *lexeme) isWord(word string) bool {
+	return l.kind == lxWord && strings.EqualFold(l.str, word)
+}
+
+func (l *lexeme) isSymbol(symbol string) bool {
+	return l.kind == lxSymbol && l.str == symbol
+}
+
+// lexTokensはSQL文のトークンを空白、語、記号、文字列リテラルに分ける
+// バインド変数、埋め込み変数のトークンは一つの値として扱う
+func lexTokens(tokens []token) []lexeme {
+	var lexemes []lexeme
+	for i, tok := range tokens {
+		switch tok.kind {
+		case tkSQLStmt:
+			lexemes = lexSQL(lexemes, i, tok.str)
+		case tkBind, tkEmbed:
+			lexemes = append(lexemes, lexeme{tok: i, kind: lxBind, str: tok.str})
+		}
+	}
+	return lexemes
+}
+
+func lexSQL(lexemes []lexeme, tok int, str string) []lexeme {
+	for len(str) > 0 {
+		r, size := utf8.DecodeRuneInString(str)
+		kind := lxSymbol
+		n := size
+		switch {
+		case unicode.IsSpace(r):
+			kind = lxSpace
+			n = len(str) - len(strings.TrimLeftFunc(str, unicode.IsSpace))
+		case r == '\'' || r == '"' || r == '`':
+			kind = lxQuoted
+			n = quotedLen(str)
+		case isIdentRune(r):
+			kind = lxWord
+			n = len(str) - len(strings.TrimLeftFunc(str, isIdentRune))
+		}
+		lexemes = append(lexemes, lexeme{tok: tok, kind: kind, str: str[:n]})
+		str = str[n:]
+	}
+	return lexemes
+}
+
+// quotedLenは先頭の引用符で囲まれた部分の長さを返す。引用符を二つ重ねるとエスケープになる
+func quotedLen(str string) int {
+	quote := str[0]
+	for i := 1; i < len(str); i++ {
+		if str[i] != quote {
+			continue
+		}
+		if i+1 < len(str) && str[i+1] == quote {
+			i++
+			continue
+		}
+		return i + 1
+	}
+	return len(str)
+}
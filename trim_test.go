@@ -0,0 +1,219 @@
+package twowaysql
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestTrimClauses(t *testing.T) {
+	tests := []struct {
+		name        string
+		input       string
+		inputParams Info
+		wantQuery   string
+		wantParams  []interface{}
+	}{
+		{
+			name:        "empty where",
+			input:       `SELECT * FROM person WHERE /* IF deptNo */ dept_no = /*deptNo*/1 /* END */`,
+			inputParams: Info{},
+			wantQuery:   `SELECT * FROM person`,
+			wantParams:  []interface{}{},
+		},
+		{
+			name:        "empty where before order by",
+			input:       `SELECT * FROM person WHERE /* IF deptNo */ dept_no = /*deptNo*/1 /* END */ /* IF name */ AND first_name = /*name*/'Tim' /* END */ ORDER BY employee_no`,
+			inputParams: Info{},
+			wantQuery:   `SELECT * FROM person ORDER BY employee_no`,
+			wantParams:  []interface{}{},
+		},
+		{
+			name:        "leading and",
+			input:       `SELECT * FROM person WHERE /* IF deptNo */ dept_no = /*deptNo*/1 /* END */ /* IF name */ AND first_name = /*name*/'Tim' /* END */ ORDER BY employee_no`,
+			inputParams: Info{Name: "Jeff"},
+			wantQuery:   `SELECT * FROM person WHERE first_name = ?/*name*/ ORDER BY employee_no`,
+			wantParams:  []interface{}{"Jeff"},
+		},
+		{
+			name:        "leading or",
+			input:       `SELECT * FROM person WHERE /* IF deptNo */ dept_no = /*deptNo*/1 /* END */ /* IF name */ or first_name = /*name*/'Tim' /* END */`,
+			inputParams: Info{Name: "Jeff"},
+			wantQuery:   `SELECT * FROM person WHERE first_name = ?/*name*/`,
+			wantParams:  []interface{}{"Jeff"},
+		},
+		{
+			name:        "trailing and in parenthesis",
+			input:       `SELECT * FROM person WHERE (dept_no = /*deptNo*/1 AND /* IF name */ first_name = /*name*/'Tim' /* END */) OR employee_no = 1`,
+			inputParams: Info{DeptNo: 2},
+			wantQuery:   `SELECT * FROM person WHERE (dept_no = ?/*deptNo*/) OR employee_no = 1`,
+			wantParams:  []interface{}{2},
+		},
+		{
+			name:        "empty having",
+			input:       `SELECT dept_no, count(*) FROM person GROUP BY dept_no HAVING /* IF maxEmpNo */ count(*) < /*maxEmpNo*/10 /* END */`,
+			inputParams: Info{},
+			wantQuery:   `SELECT dept_no, count(*) FROM person GROUP BY dept_no`,
+			wantParams:  []interface{}{},
+		},
+		{
+			name:        "empty where in sub query",
+			input:       `SELECT * FROM person WHERE dept_no IN (SELECT dept_no FROM dept WHERE /* IF name */ name = /*name*/'HR' /* END */)`,
+			inputParams: Info{},
+			wantQuery:   `SELECT * FROM person WHERE dept_no IN (SELECT dept_no FROM dept)`,
+			wantParams:  []interface{}{},
+		},
+		{
+			name:        "trailing comma in set",
+			input:       `UPDATE person SET first_name = /*firstName*/'Tim', /* IF email */ email = /*email*/'a@example.com' /* END */ WHERE employee_no = /*EmpNo*/1`,
+			inputParams: Info{FirstName: "Jeff", EmpNo: 3},
+			wantQuery:   `UPDATE person SET first_name = ?/*firstName*/ WHERE employee_no = ?/*EmpNo*/`,
+			wantParams:  []interface{}{"Jeff", 3},
+		},
+		{
+			name:        "leading comma in set",
+			input:       `UPDATE person SET /* IF firstName */ first_name = /*firstName*/'Tim' /* END */ , email = /*email*/'a@example.com' WHERE employee_no = /*EmpNo*/1`,
+			inputParams: Info{Email: "jeff@example.com", EmpNo: 3},
+			wantQuery:   `UPDATE person SET email = ?/*email*/ WHERE employee_no = ?/*EmpNo*/`,
+			wantParams:  []interface{}{"jeff@example.com", 3},
+		},
+		{
+			name:        "trailing comma in select list",
+			input:       `SELECT employee_no, first_name, /* IF checked */ email /* END */ FROM person`,
+			inputParams: Info{},
+			wantQuery:   `SELECT employee_no, first_name FROM person`,
+			wantParams:  []interface{}{},
+		},
+		{
+			name:        "double comma in select list",
+			input:       `SELECT employee_no, /* IF checked */ email, /* END */ first_name FROM person`,
+			inputParams: Info{},
+			wantQuery:   `SELECT employee_no, first_name FROM person`,
+			wantParams:  []interface{}{},
+		},
+		{
+			name:        "keep literals",
+			input:       `SELECT 'WHERE', 'a,' FROM person WHERE first_name = ', AND' /* IF name */ AND last_name = /*name*/'Tim' /* END */`,
+			inputParams: Info{},
+			wantQuery:   `SELECT 'WHERE', 'a,' FROM person WHERE first_name = ', AND'`,
+			wantParams:  []interface{}{},
+		},
+		{
+			name:        "keep column named like keyword",
+			input:       `SELECT employee_no, offset FROM person WHERE offset = /*maxEmpNo*/1 /* IF name */ AND first_name = /*name*/'Tim' /* END */`,
+			inputParams: Info{MaxEmpNo: 5},
+			wantQuery:   `SELECT employee_no, offset FROM person WHERE offset = ?/*maxEmpNo*/`,
+			wantParams:  []interface{}{5},
+		},
+		{
+			name:        "limit",
+			input:       `SELECT * FROM person WHERE /* IF name */ first_name = /*name*/'Tim' /* END */ LIMIT /*maxEmpNo*/10`,
+			inputParams: Info{MaxEmpNo: 5},
+			wantQuery:   `SELECT * FROM person LIMIT ?/*maxEmpNo*/`,
+			wantParams:  []interface{}{5},
+		},
+		{
+			name:        "empty parenthesis",
+			input:       `SELECT * FROM person WHERE (/* IF name */ first_name = /*name*/'Tim' /* END */) AND dept_no = /*deptNo*/1`,
+			inputParams: Info{DeptNo: 2},
+			wantQuery:   `SELECT * FROM person WHERE dept_no = ?/*deptNo*/`,
+			wantParams:  []interface{}{2},
+		},
+		{
+			name:        "empty nested parenthesis",
+			input:       `SELECT * FROM person WHERE dept_no = /*deptNo*/1 OR ((/* IF name */ first_name = /*name*/'Tim' /* END */) /* IF email */ AND email = /*email*/'a' /* END */)`,
+			inputParams: Info{DeptNo: 2},
+			wantQuery:   `SELECT * FROM person WHERE dept_no = ?/*deptNo*/`,
+			wantParams:  []interface{}{2},
+		},
+		{
+			name:        "only empty parenthesis",
+			input:       `SELECT * FROM person WHERE (/* IF name */ first_name = /*name*/'Tim' /* END */ /* IF email */ OR email = /*email*/'a' /* END */) ORDER BY employee_no`,
+			inputParams: Info{},
+			wantQuery:   `SELECT * FROM person ORDER BY employee_no`,
+			wantParams:  []interface{}{},
+		},
+		{
+			name:        "keep function call",
+			input:       `SELECT count() FROM person WHERE created_at < now() /* IF name */ AND first_name = /*name*/'Tim' /* END */`,
+			inputParams: Info{},
+			wantQuery:   `SELECT count() FROM person WHERE created_at < now()`,
+			wantParams:  []interface{}{},
+		},
+		{
+			name:        "for update",
+			input:       `SELECT * FROM person WHERE /* IF name */ first_name = /*name*/'Tim' /* END */ FOR UPDATE`,
+			inputParams: Info{},
+			wantQuery:   `SELECT * FROM person FOR UPDATE`,
+			wantParams:  []interface{}{},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if query, params, err := Eval(tt.input, &tt.inputParams); err != nil || query != tt.wantQuery || !interfaceSliceEqual(params, tt.wantParams) {
+				if err != nil {
+					t.Error(err)
+				}
+				if query != tt.wantQuery {
+					t.Errorf("Doesn't Match\nexpected: \n%s\n but got: \n%s\n", tt.wantQuery, query)
+				}
+				if !interfaceSliceEqual(params, tt.wantParams) {
+					t.Errorf("Doesn't Match\nexpected: \n%v\n but got: \n%v\n", tt.wantParams, params)
+				}
+			}
+		})
+	}
+}
+
+func TestTrimClausesEmptyWhere(t *testing.T) {
+	tests := []struct {
+		name        string
+		input       string
+		inputParams Info
+	}{
+		{
+			name:        "update",
+			input:       `UPDATE person SET first_name = /*firstName*/'Tim' WHERE /* IF EmpNo */ employee_no = /*EmpNo*/1 /* END */`,
+			inputParams: Info{FirstName: "Jeff"},
+		},
+		{
+			name:        "delete",
+			input:       `DELETE FROM person WHERE /* IF EmpNo */ employee_no = /*EmpNo*/1 /* END */`,
+			inputParams: Info{},
+		},
+		{
+			name:        "delete with skipped and",
+			input:       `DELETE FROM person WHERE /* IF EmpNo */ employee_no = /*EmpNo*/1 /* END */ /* IF deptNo */ AND dept_no = /*deptNo*/1 /* END */`,
+			inputParams: Info{},
+		},
+		{
+			name:        "delete with empty parenthesis",
+			input:       `DELETE FROM person WHERE (/* IF EmpNo */ employee_no = /*EmpNo*/1 /* END */)`,
+			inputParams: Info{},
+		},
+		{
+			name:        "delete in with",
+			input:       `WITH deleted AS (DELETE FROM person WHERE /* IF EmpNo */ employee_no = /*EmpNo*/1 /* END */ RETURNING *) SELECT count(*) FROM deleted`,
+			inputParams: Info{},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if query, _, err := Eval(tt.input, &tt.inputParams); !errors.Is(err, ErrEmptyWhere) {
+				t.Errorf("Doesn't Match\nexpected: \n%v\n but got: \n%v (%s)\n", ErrEmptyWhere, err, query)
+			}
+		})
+	}
+}
+
+func TestTrimClausesWhereInSubQueryOfDelete(t *testing.T) {
+	query, _, err := Eval(`DELETE FROM person WHERE dept_no IN (SELECT dept_no FROM dept WHERE /* IF name */ name = /*name*/'HR' /* END */)`, &Info{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := `DELETE FROM person WHERE dept_no IN (SELECT dept_no FROM dept)`
+	if query != want {
+		t.Errorf("Doesn't Match\nexpected: \n%s\n but got: \n%s\n", want, query)
+	}
+}
@@ -11,55 +11,240 @@ import (
 
 // Twowaysql is a struct for issuing 2WaySQL query
 type Twowaysql struct {
-	db *sqlx.DB
+	db   *sqlx.DB
+	opts []Option
+	o    *options
 }
 
 // New returns instance of Twowaysql
-func New(db *sqlx.DB) *Twowaysql {
+// opts are applied to every query issued through it.
+// The placeholders are chosen from the driver name of db unless WithPlaceholder is given.
+func New(db *sqlx.DB, opts ...Option) *Twowaysql {
+	o := newOptions(opts)
+	if o.placeholder == 0 {
+		o.placeholder = placeholderOf(db.DriverName())
+	}
 	return &Twowaysql{
-		db: db,
+		db:   db,
+		opts: opts,
+		o:    o,
 	}
 }
 
 // Select is a thin wrapper around db.Select in the sqlx package.
-// params takes a tagged struct. The tags format must be `twowaysql:"tag_name"`.
+// params takes a tagged struct or a map[string]interface{}. The tags format must be `twowaysql:"tag_name"`.
 // dest takes a pointer to a slice of a struct. The struct tag format must be `db:"tag_name"`.
 func (t *Twowaysql) Select(ctx context.Context, dest interface{}, query string, params interface{}) error {
 
-	eval, bindParams, err := Eval(query, params)
+	tmpl, err := Compile(query, t.opts...)
+	if err != nil {
+		return err
+	}
+
+	return t.SelectTemplate(ctx, dest, tmpl, params)
+}
+
+// SelectTemplate is equivalent to Select, but takes a compiled template instead of a query.
+// Use it to avoid parsing the same query on every call.
+// The placeholders are written in the style of t, not the one given to Compile.
+func (t *Twowaysql) SelectTemplate(ctx context.Context, dest interface{}, tmpl *Template, params interface{}) error {
+
+	eval, bindParams, err := tmpl.eval(params, t.o.placeholder)
+	if err != nil {
+		return err
+	}
+
+	return t.db.SelectContext(ctx, dest, eval, bindParams...)
+
+}
+
+// Get is a thin wrapper around db.Get in the sqlx package.
+// params takes a tagged struct or a map[string]interface{}. The tags format must be `twowaysql:"tag_name"`.
+// dest takes a pointer to a struct or a scannable value. The struct tag format must be `db:"tag_name"`.
+// It returns sql.ErrNoRows when no row matches, and ErrTooManyRows for more than one row if WithStrictGet is given.
+func (t *Twowaysql) Get(ctx context.Context, dest interface{}, query string, params interface{}) error {
+
+	tmpl, err := Compile(query, t.opts...)
+	if err != nil {
+		return err
+	}
+
+	return t.GetTemplate(ctx, dest, tmpl, params)
+}
+
+// GetTemplate is equivalent to Get, but takes a compiled template instead of a query.
+func (t *Twowaysql) GetTemplate(ctx context.Context, dest interface{}, tmpl *Template, params interface{}) error {
+
+	eval, bindParams, err := tmpl.eval(params, t.o.placeholder)
 	if err != nil {
 		return err
 	}
 
-	q := t.db.Rebind(eval)
+	return get(ctx, t.db, t.o.strictGet, dest, eval, bindParams...)
+}
+
+// Query is a thin wrapper around db.Queryx in the sqlx package.
+// params takes a tagged struct or a map[string]interface{}. The tags format must be `twowaysql:"tag_name"`.
+// Unlike Select, the rows are read one by one, so it can handle large result sets.
+// The returned Rows must be closed, or consumed with Iter.
+func (t *Twowaysql) Query(ctx context.Context, query string, params interface{}) (*Rows, error) {
 
-	return t.db.SelectContext(ctx, dest, q, bindParams...)
+	tmpl, err := Compile(query, t.opts...)
+	if err != nil {
+		return nil, err
+	}
 
+	return t.QueryTemplate(ctx, tmpl, params)
+}
+
+// QueryTemplate is equivalent to Query, but takes a compiled template instead of a query.
+func (t *Twowaysql) QueryTemplate(ctx context.Context, tmpl *Template, params interface{}) (*Rows, error) {
+
+	eval, bindParams, err := tmpl.eval(params, t.o.placeholder)
+	if err != nil {
+		return nil, err
+	}
+
+	rows, err := t.db.QueryxContext(ctx, eval, bindParams...)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Rows{rows: rows}, nil
+}
+
+// SelectFile is equivalent to Select, but takes the name of a file read by the Loader given with WithLoader.
+func (t *Twowaysql) SelectFile(ctx context.Context, dest interface{}, name string, params interface{}) error {
+
+	tmpl, err := t.o.load(name)
+	if err != nil {
+		return err
+	}
+
+	return t.SelectTemplate(ctx, dest, tmpl, params)
+}
+
+// GetFile is equivalent to Get, but takes the name of a file read by the Loader given with WithLoader.
+func (t *Twowaysql) GetFile(ctx context.Context, dest interface{}, name string, params interface{}) error {
+
+	tmpl, err := t.o.load(name)
+	if err != nil {
+		return err
+	}
+
+	return t.GetTemplate(ctx, dest, tmpl, params)
+}
+
+// QueryFile is equivalent to Query, but takes the name of a file read by the Loader given with WithLoader.
+func (t *Twowaysql) QueryFile(ctx context.Context, name string, params interface{}) (*Rows, error) {
+
+	tmpl, err := t.o.load(name)
+	if err != nil {
+		return nil, err
+	}
+
+	return t.QueryTemplate(ctx, tmpl, params)
+}
+
+// ExecFile is equivalent to Exec, but takes the name of a file read by the Loader given with WithLoader.
+func (t *Twowaysql) ExecFile(ctx context.Context, name string, params interface{}) (sql.Result, error) {
+
+	tmpl, err := t.o.load(name)
+	if err != nil {
+		return nil, err
+	}
+
+	return t.ExecTemplate(ctx, tmpl, params)
+}
+
+// SelectNamed is equivalent to Select, but takes the name of a query in the Registry given with WithRegistry.
+func (t *Twowaysql) SelectNamed(ctx context.Context, dest interface{}, name string, params interface{}) error {
+
+	tmpl, err := t.o.lookup(name)
+	if err != nil {
+		return err
+	}
+
+	return t.SelectTemplate(ctx, dest, tmpl, params)
+}
+
+// GetNamed is equivalent to Get, but takes the name of a query in the Registry given with WithRegistry.
+func (t *Twowaysql) GetNamed(ctx context.Context, dest interface{}, name string, params interface{}) error {
+
+	tmpl, err := t.o.lookup(name)
+	if err != nil {
+		return err
+	}
+
+	return t.GetTemplate(ctx, dest, tmpl, params)
+}
+
+// QueryNamed is equivalent to Query, but takes the name of a query in the Registry given with WithRegistry.
+func (t *Twowaysql) QueryNamed(ctx context.Context, name string, params interface{}) (*Rows, error) {
+
+	tmpl, err := t.o.lookup(name)
+	if err != nil {
+		return nil, err
+	}
+
+	return t.QueryTemplate(ctx, tmpl, params)
+}
+
+// ExecNamed is equivalent to Exec, but takes the name of a query in the Registry given with WithRegistry.
+func (t *Twowaysql) ExecNamed(ctx context.Context, name string, params interface{}) (sql.Result, error) {
+
+	tmpl, err := t.o.lookup(name)
+	if err != nil {
+		return nil, err
+	}
+
+	return t.ExecTemplate(ctx, tmpl, params)
 }
 
 // Exec is a thin wrapper around db.Exec in the sqlx package.
-// params takes a tagged struct. The tags format must be `twowaysql:"tag_name"`.
+// params takes a tagged struct or a map[string]interface{}. The tags format must be `twowaysql:"tag_name"`.
 func (t *Twowaysql) Exec(ctx context.Context, query string, params interface{}) (sql.Result, error) {
 
-	eval, bindParams, err := Eval(query, params)
+	tmpl, err := Compile(query, t.opts...)
 	if err != nil {
 		return nil, err
 	}
 
-	q := t.db.Rebind(eval)
+	return t.ExecTemplate(ctx, tmpl, params)
+}
 
-	return t.db.ExecContext(ctx, q, bindParams...)
+// ExecTemplate is equivalent to Exec, but takes a compiled template instead of a query.
+// Use it to avoid parsing the same query on every call.
+func (t *Twowaysql) ExecTemplate(ctx context.Context, tmpl *Template, params interface{}) (sql.Result, error) {
+
+	eval, bindParams, err := tmpl.eval(params, t.o.placeholder)
+	if err != nil {
+		return nil, err
+	}
+
+	return t.db.ExecContext(ctx, eval, bindParams...)
 }
 
 // Begin is a thin wrapper around db.BeginTxx in the sqlx package.
 func (t *Twowaysql) Begin(ctx context.Context) (*TwowaysqlTx, error) {
+	return t.BeginTx(ctx, nil)
+}
+
+// BeginTx is a thin wrapper around db.BeginTxx in the sqlx package.
+// txOpts sets the isolation level and the read-only flag. nil means the driver's default.
+func (t *Twowaysql) BeginTx(ctx context.Context, txOpts *sql.TxOptions) (*TwowaysqlTx, error) {
 
-	tx, err := t.db.BeginTxx(ctx, nil)
+	tx, err := t.db.BeginTxx(ctx, txOpts)
 	if err != nil {
 		return nil, err
 	}
 
-	return &TwowaysqlTx{tx: tx}, nil
+	var txOptions sql.TxOptions
+	if txOpts != nil {
+		txOptions = *txOpts
+	}
+
+	return &TwowaysqlTx{tx: tx, opts: t.opts, o: t.o, txOptions: txOptions, savepoints: newSavepoints(tx)}, nil
 }
 
 // Close is a thin wrapper around db.Close in the sqlx package.
@@ -74,16 +259,31 @@ func (t *Twowaysql) Close(ctx context.Context) error {
 
 // Transaction starts a transaction as a block.
 // arguments function is return error will rollback, otherwise to commit.
-func (t *Twowaysql) Transaction(ctx context.Context, fn func(tx TwowaysqlTx) error) error {
-	tx, err := t.Begin(ctx)
+// If the function panics, the transaction is rolled back and the panic continues, unless WithPanicAsError is given.
+// When the rollback fails too, both errors are returned joined by errors.Join.
+// tx satisfies Querier, so it can be passed to code that also runs without a transaction.
+func (t *Twowaysql) Transaction(ctx context.Context, fn func(tx *TwowaysqlTx) error) error {
+	return t.TransactionWithOptions(ctx, nil, fn)
+}
+
+// TransactionWithOptions is equivalent to Transaction, but starts the transaction with txOpts.
+// With WithRetryPolicy, fn is run again in a new transaction when it or the commit fails with a retryable error.
+func (t *Twowaysql) TransactionWithOptions(ctx context.Context, txOpts *sql.TxOptions, fn func(tx *TwowaysqlTx) error) error {
+	if t.o.retry != nil {
+		return t.o.retry.retry(ctx, func() error {
+			return t.transaction(ctx, txOpts, fn)
+		})
+	}
+	return t.transaction(ctx, txOpts, fn)
+}
+
+func (t *Twowaysql) transaction(ctx context.Context, txOpts *sql.TxOptions, fn func(tx *TwowaysqlTx) error) error {
+	tx, err := t.BeginTx(ctx, txOpts)
 	if err != nil {
 		return err
 	}
 
-	if err := fn(*tx); err != nil {
-		if rerr := tx.Rollback(); rerr != nil {
-			return fmt.Errorf("failed rollback %v: %w", rerr, err)
-		}
+	if err := runTx(func() error { return fn(tx) }, tx.Rollback, t.o.panicAsError); err != nil {
 		return err
 	}
 
@@ -96,12 +296,33 @@ func (t *Twowaysql) Transaction(ctx context.Context, fn func(tx TwowaysqlTx) err
 
 // TwowaysqlTx is a structure for issuing 2WaySQL queries within a transaction.
 type TwowaysqlTx struct {
-	tx *sqlx.Tx
+	tx         *sqlx.Tx
+	opts       []Option
+	o          *options
+	txOptions  sql.TxOptions
+	savepoints *savepoints
+	savepoint  string /* name of the savepoint for a nested transaction */
+}
+
+// TxOptions returns the options the transaction was started with.
+// The zero value means the driver's default.
+func (t *TwowaysqlTx) TxOptions() sql.TxOptions {
+	return t.txOptions
+}
+
+// ReadOnly reports whether the transaction was started as read-only.
+func (t *TwowaysqlTx) ReadOnly() bool {
+	return t.txOptions.ReadOnly
 }
 
 // Commit is a thin wrapper around tx.Commit in the sqlx package.
+// It returns ErrNestedTx for a nested transaction.
 func (t *TwowaysqlTx) Commit() error {
 
+	if t.savepoint != "" {
+		return ErrNestedTx
+	}
+
 	if err := t.tx.Commit(); err != nil {
 		return err
 	}
@@ -110,8 +331,13 @@ func (t *TwowaysqlTx) Commit() error {
 }
 
 // Rollback is a thin wrapper around tx.Rollback in the sqlx package.
+// It returns ErrNestedTx for a nested transaction.
 func (t *TwowaysqlTx) Rollback() error {
 
+	if t.savepoint != "" {
+		return ErrNestedTx
+	}
+
 	if err := t.tx.Rollback(); err != nil {
 		return err
 	}
@@ -120,33 +346,207 @@ func (t *TwowaysqlTx) Rollback() error {
 }
 
 // Select is a thin wrapper around db.Select in the sqlx package.
-// params takes a tagged struct. The tags format must be `twowaysql:"tag_name"`.
+// params takes a tagged struct or a map[string]interface{}. The tags format must be `twowaysql:"tag_name"`.
 // dest takes a pointer to a slice of a struct. The struct tag format must be `db:"tag_name"`.
 // It is an equivalent implementation of Twowaysql.Select
 func (t *TwowaysqlTx) Select(ctx context.Context, dest interface{}, query string, params interface{}) error {
 
-	eval, bindParams, err := Eval(query, params)
+	tmpl, err := Compile(query, t.opts...)
+	if err != nil {
+		return err
+	}
+
+	return t.SelectTemplate(ctx, dest, tmpl, params)
+}
+
+// SelectTemplate is equivalent to Select, but takes a compiled template instead of a query.
+// It is an equivalent implementation of Twowaysql.SelectTemplate
+func (t *TwowaysqlTx) SelectTemplate(ctx context.Context, dest interface{}, tmpl *Template, params interface{}) error {
+
+	eval, bindParams, err := tmpl.eval(params, t.o.placeholder)
 	if err != nil {
 		return err
 	}
 
-	q := t.tx.Rebind(eval)
+	return t.tx.SelectContext(ctx, dest, eval, bindParams...)
+
+}
 
-	return t.tx.SelectContext(ctx, dest, q, bindParams...)
+// Get is a thin wrapper around db.Get in the sqlx package.
+// params takes a tagged struct or a map[string]interface{}. The tags format must be `twowaysql:"tag_name"`.
+// dest takes a pointer to a struct or a scannable value. The struct tag format must be `db:"tag_name"`.
+// It is an equivalent implementation of Twowaysql.Get
+func (t *TwowaysqlTx) Get(ctx context.Context, dest interface{}, query string, params interface{}) error {
+
+	tmpl, err := Compile(query, t.opts...)
+	if err != nil {
+		return err
+	}
 
+	return t.GetTemplate(ctx, dest, tmpl, params)
+}
+
+// GetTemplate is equivalent to Get, but takes a compiled template instead of a query.
+// It is an equivalent implementation of Twowaysql.GetTemplate
+func (t *TwowaysqlTx) GetTemplate(ctx context.Context, dest interface{}, tmpl *Template, params interface{}) error {
+
+	eval, bindParams, err := tmpl.eval(params, t.o.placeholder)
+	if err != nil {
+		return err
+	}
+
+	return get(ctx, t.tx, t.o.strictGet, dest, eval, bindParams...)
+}
+
+// Query is a thin wrapper around db.Queryx in the sqlx package.
+// params takes a tagged struct or a map[string]interface{}. The tags format must be `twowaysql:"tag_name"`.
+// Unlike Select, the rows are read one by one, so it can handle large result sets.
+// The returned Rows must be closed, or consumed with Iter.
+// It is an equivalent implementation of Twowaysql.Query
+func (t *TwowaysqlTx) Query(ctx context.Context, query string, params interface{}) (*Rows, error) {
+
+	tmpl, err := Compile(query, t.opts...)
+	if err != nil {
+		return nil, err
+	}
+
+	return t.QueryTemplate(ctx, tmpl, params)
+}
+
+// QueryTemplate is equivalent to Query, but takes a compiled template instead of a query.
+// It is an equivalent implementation of Twowaysql.QueryTemplate
+func (t *TwowaysqlTx) QueryTemplate(ctx context.Context, tmpl *Template, params interface{}) (*Rows, error) {
+
+	eval, bindParams, err := tmpl.eval(params, t.o.placeholder)
+	if err != nil {
+		return nil, err
+	}
+
+	rows, err := t.tx.QueryxContext(ctx, eval, bindParams...)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Rows{rows: rows}, nil
+}
+
+// SelectFile is equivalent to Select, but takes the name of a file read by the Loader given with WithLoader.
+// It is an equivalent implementation of Twowaysql.SelectFile
+func (t *TwowaysqlTx) SelectFile(ctx context.Context, dest interface{}, name string, params interface{}) error {
+
+	tmpl, err := t.o.load(name)
+	if err != nil {
+		return err
+	}
+
+	return t.SelectTemplate(ctx, dest, tmpl, params)
+}
+
+// GetFile is equivalent to Get, but takes the name of a file read by the Loader given with WithLoader.
+// It is an equivalent implementation of Twowaysql.GetFile
+func (t *TwowaysqlTx) GetFile(ctx context.Context, dest interface{}, name string, params interface{}) error {
+
+	tmpl, err := t.o.load(name)
+	if err != nil {
+		return err
+	}
+
+	return t.GetTemplate(ctx, dest, tmpl, params)
+}
+
+// QueryFile is equivalent to Query, but takes the name of a file read by the Loader given with WithLoader.
+// It is an equivalent implementation of Twowaysql.QueryFile
+func (t *TwowaysqlTx) QueryFile(ctx context.Context, name string, params interface{}) (*Rows, error) {
+
+	tmpl, err := t.o.load(name)
+	if err != nil {
+		return nil, err
+	}
+
+	return t.QueryTemplate(ctx, tmpl, params)
+}
+
+// ExecFile is equivalent to Exec, but takes the name of a file read by the Loader given with WithLoader.
+// It is an equivalent implementation of Twowaysql.ExecFile
+func (t *TwowaysqlTx) ExecFile(ctx context.Context, name string, params interface{}) (sql.Result, error) {
+
+	tmpl, err := t.o.load(name)
+	if err != nil {
+		return nil, err
+	}
+
+	return t.ExecTemplate(ctx, tmpl, params)
+}
+
+// SelectNamed is equivalent to Select, but takes the name of a query in the Registry given with WithRegistry.
+// It is an equivalent implementation of Twowaysql.SelectNamed
+func (t *TwowaysqlTx) SelectNamed(ctx context.Context, dest interface{}, name string, params interface{}) error {
+
+	tmpl, err := t.o.lookup(name)
+	if err != nil {
+		return err
+	}
+
+	return t.SelectTemplate(ctx, dest, tmpl, params)
+}
+
+// GetNamed is equivalent to Get, but takes the name of a query in the Registry given with WithRegistry.
+// It is an equivalent implementation of Twowaysql.GetNamed
+func (t *TwowaysqlTx) GetNamed(ctx context.Context, dest interface{}, name string, params interface{}) error {
+
+	tmpl, err := t.o.lookup(name)
+	if err != nil {
+		return err
+	}
+
+	return t.GetTemplate(ctx, dest, tmpl, params)
+}
+
+// QueryNamed is equivalent to Query, but takes the name of a query in the Registry given with WithRegistry.
+// It is an equivalent implementation of Twowaysql.QueryNamed
+func (t *TwowaysqlTx) QueryNamed(ctx context.Context, name string, params interface{}) (*Rows, error) {
+
+	tmpl, err := t.o.lookup(name)
+	if err != nil {
+		return nil, err
+	}
+
+	return t.QueryTemplate(ctx, tmpl, params)
+}
+
+// ExecNamed is equivalent to Exec, but takes the name of a query in the Registry given with WithRegistry.
+// It is an equivalent implementation of Twowaysql.ExecNamed
+func (t *TwowaysqlTx) ExecNamed(ctx context.Context, name string, params interface{}) (sql.Result, error) {
+
+	tmpl, err := t.o.lookup(name)
+	if err != nil {
+		return nil, err
+	}
+
+	return t.ExecTemplate(ctx, tmpl, params)
 }
 
 // Exec is a thin wrapper around db.Exec in the sqlx package.
-// params takes a tagged struct. The tags format must be `twowaysql:"tag_name"`.
+// params takes a tagged struct or a map[string]interface{}. The tags format must be `twowaysql:"tag_name"`.
 // It is an equivalent implementation of Twowaysql.Exec
 func (t *TwowaysqlTx) Exec(ctx context.Context, query string, params interface{}) (sql.Result, error) {
 
-	eval, bindParams, err := Eval(query, params)
+	tmpl, err := Compile(query, t.opts...)
 	if err != nil {
 		return nil, err
 	}
 
-	q := t.tx.Rebind(eval)
+	return t.ExecTemplate(ctx, tmpl, params)
+}
+
+// ExecTemplate is equivalent to Exec, but takes a compiled template instead of a query.
+// It is an equivalent implementation of Twowaysql.ExecTemplate
+func (t *TwowaysqlTx) ExecTemplate(ctx context.Context, tmpl *Template, params interface{}) (sql.Result, error) {
+
+	eval, bindParams, err := tmpl.eval(params, t.o.placeholder)
+	if err != nil {
+		return nil, err
+	}
 
-	return t.tx.ExecContext(ctx, q, bindParams...)
+	return t.tx.ExecContext(ctx, eval, bindParams...)
 }
@@ -0,0 +1,337 @@
+package twowaysql
+
+import (
+	"database/sql/driver"
+	"fmt"
+	"math"
+	"reflect"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+var (
+	timeType   = reflect.TypeOf(time.Time{})
+	valuerType = reflect.TypeOf((*driver.Valuer)(nil)).Elem()
+)
+
+// isValueTypeは構造体であっても一つの値として扱う型かを返す
+func isValueType(t reflect.Type) bool {
+	return t == timeType || t.Implements(valuerType) || reflect.PtrTo(t).Implements(valuerType)
+}
+
+// normalizeはパラメータの値を条件式で扱いやすい形に揃える
+// 整数はint64、浮動小数点数はfloat64、名前付きの型は基底の型に変換する
+// driver.Valuerはその値を使い、nilのポインタなどはnilにする
+func normalize(v interface{}) interface{} {
+	if v == nil {
+		return nil
+	}
+	if valuer, ok := v.(driver.Valuer); ok {
+		rv := reflect.ValueOf(v)
+		if rv.Kind() == reflect.Ptr && rv.IsNil() {
+			return nil
+		}
+		value, err := valuer.Value()
+		if err != nil {
+			return v
+		}
+		return normalize(value)
+	}
+
+	rv := reflect.ValueOf(v)
+	for rv.Kind() == reflect.Ptr || rv.Kind() == reflect.Interface {
+		if rv.IsNil() {
+			return nil
+		}
+		rv = rv.Elem()
+	}
+
+	switch rv.Kind() {
+	case reflect.Bool:
+		return rv.Bool()
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return rv.Int()
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		if u := rv.Uint(); u <= math.MaxInt64 {
+			return int64(u)
+		}
+		return float64(rv.Uint())
+	case reflect.Float32, reflect.Float64:
+		return rv.Float()
+	case reflect.String:
+		return rv.String()
+	case reflect.Slice, reflect.Map:
+		if rv.IsNil() {
+			return nil
+		}
+	}
+	if rv.Type() == timeType {
+		return rv.Interface().(time.Time)
+	}
+	return rv.Interface()
+}
+
+// truthyは値を真偽値として評価する
+// nil, false, 0, 空文字列, 空のスライス・マップは偽になる
+func truthy(v interface{}) bool {
+	switch v := normalize(v).(type) {
+	case nil:
+		return false
+	case bool:
+		return v
+	case int64:
+		return v != 0
+	case float64:
+		return v != 0 && !math.IsNaN(v)
+	case string:
+		return v != ""
+	default:
+		rv := reflect.ValueOf(v)
+		switch rv.Kind() {
+		case reflect.Slice, reflect.Array, reflect.Map:
+			return rv.Len() > 0
+		}
+		return true
+	}
+}
+
+// equalは二つの値が等しいかを返す。数値は型が違っても値で比較する
+func equal(x, y interface{}) bool {
+	x, y = normalize(x), normalize(y)
+	if x == nil || y == nil {
+		return x == nil && y == nil
+	}
+	if c, ok := compareNumber(x, y); ok {
+		return c == 0
+	}
+	if xt, ok := x.(time.Time); ok {
+		if yt, ok := y.(time.Time); ok {
+			return xt.Equal(yt)
+		}
+		return false
+	}
+	return reflect.DeepEqual(x, y)
+}
+
+// compareは大小関係を返す。比較できない組み合わせの場合はokがfalseになる
+func compare(x, y interface{}) (int, bool) {
+	x, y = normalize(x), normalize(y)
+	if c, ok := compareNumber(x, y); ok {
+		return c, true
+	}
+	switch xv := x.(type) {
+	case string:
+		if yv, ok := y.(string); ok {
+			return strings.Compare(xv, yv), true
+		}
+	case time.Time:
+		if yv, ok := y.(time.Time); ok {
+			switch {
+			case xv.Before(yv):
+				return -1, true
+			case xv.After(yv):
+				return 1, true
+			}
+			return 0, true
+		}
+	}
+	return 0, false
+}
+
+// compareNumberはnormalize済みの数値同士を比較する
+func compareNumber(x, y interface{}) (int, bool) {
+	xi, xIsInt := x.(int64)
+	yi, yIsInt := y.(int64)
+	if xIsInt && yIsInt {
+		switch {
+		case xi < yi:
+			return -1, true
+		case xi > yi:
+			return 1, true
+		}
+		return 0, true
+	}
+	xf, ok := toFloat(x)
+	if !ok {
+		return 0, false
+	}
+	yf, ok := toFloat(y)
+	if !ok {
+		return 0, false
+	}
+	switch {
+	case xf < yf:
+		return -1, true
+	case xf > yf:
+		return 1, true
+	}
+	return 0, true
+}
+
+func toFloat(v interface{}) (float64, bool) {
+	switch v := v.(type) {
+	case int64:
+		return float64(v), true
+	case float64:
+		return v, true
+	}
+	return 0, false
+}
+
+// lengthは文字列、スライス、配列、マップの長さを返す
+func length(v interface{}) (int64, error) {
+	v = normalize(v)
+	if s, ok := v.(string); ok {
+		return int64(utf8.RuneCountInString(s)), nil
+	}
+	if v == nil {
+		return 0, nil
+	}
+	rv := reflect.ValueOf(v)
+	switch rv.Kind() {
+	case reflect.Slice, reflect.Array, reflect.Map:
+		return int64(rv.Len()), nil
+	}
+	return 0, fmt.Errorf("len: unsupported type %T", v)
+}
+
+// contains reports whether container holds elem.
+// container may be a slice, an array, a map (key lookup) or a string (substring).
+func contains(container, elem interface{}) (bool, error) {
+	container = normalize(container)
+	switch c := container.(type) {
+	case nil:
+		return false, nil
+	case string:
+		if s, ok := normalize(elem).(string); ok {
+			return strings.Contains(c, s), nil
+		}
+		return false, nil
+	}
+	rv := reflect.ValueOf(container)
+	switch rv.Kind() {
+	case reflect.Slice, reflect.Array:
+		for i := 0; i < rv.Len(); i++ {
+			if equal(rv.Index(i).Interface(), elem) {
+				return true, nil
+			}
+		}
+		return false, nil
+	case reflect.Map:
+		iter := rv.MapRange()
+		for iter.Next() {
+			if equal(iter.Key().Interface(), elem) {
+				return true, nil
+			}
+		}
+		return false, nil
+	}
+	return false, fmt.Errorf("in: unsupported type %T", container)
+}
+
+// fieldValueは構造体のフィールドまたはマップの要素を名前で取り出す
+// 構造体はtwowaysqlタグ、フィールド名の順に探す
+func fieldValue(v interface{}, name string) (interface{}, bool) {
+	rv := reflect.ValueOf(v)
+	for rv.Kind() == reflect.Ptr || rv.Kind() == reflect.Interface {
+		if rv.IsNil() {
+			return nil, false
+		}
+		rv = rv.Elem()
+	}
+
+	switch rv.Kind() {
+	case reflect.Map:
+		if rv.Type().Key().Kind() != reflect.String {
+			return nil, false
+		}
+		elem := rv.MapIndex(reflect.ValueOf(name).Convert(rv.Type().Key()))
+		if !elem.IsValid() {
+			return nil, false
+		}
+		return elem.Interface(), true
+	case reflect.Struct:
+		rt := rv.Type()
+		for i := 0; i < rt.NumField(); i++ {
+			f := rt.Field(i)
+			if f.PkgPath != "" {
+				continue
+			}
+			if tag := strings.Split(f.Tag.Get("twowaysql"), ",")[0]; tag == name {
+				return rv.Field(i).Interface(), true
+			}
+		}
+		if f, ok := rt.FieldByName(name); ok && f.PkgPath == "" {
+			// nilのポインタの埋め込みを通るフィールドは、ないフィールドと同じに扱う
+			field, err := rv.FieldByIndexErr(f.Index)
+			if err != nil {
+				return nil, false
+			}
+			return field.Interface(), true
+		}
+	}
+	return nil, false
+}
+
+// lookupPathはname.field.fieldの形式の参照を解決する
+// 先頭の名前はgetで取り出し、それ以降はフィールドまたはマップの要素として辿る
+// 解決できない場合はどこで辿れなくなったかを*pathErrorで返す
+func lookupPath(get func(name string) (interface{}, bool), path string) (interface{}, error) {
+	return lookupNames(get, strings.Split(path, "."))
+}
+
+func lookupNames(get func(name string) (interface{}, bool), names []string) (interface{}, error) {
+	v, ok := get(names[0])
+	if !ok {
+		return nil, &pathError{names: names}
+	}
+	for i, name := range names[1:] {
+		if isNil(v) {
+			return nil, &pathError{names: names, at: i + 1, isNil: true}
+		}
+		if v, ok = fieldValue(v, name); !ok {
+			return nil, &pathError{names: names, at: i + 1}
+		}
+	}
+	return v, nil
+}
+
+// pathErrorはname.field.fieldの形式の参照のうち解決できなかった部分を表す
+type pathError struct {
+	names []string
+	at    int /* index of the name that could not be resolved */
+	isNil bool
+}
+
+func (e *pathError) Error() string {
+	parent := strings.Join(e.names[:e.at], ".")
+	switch {
+	case e.at == 0:
+		return fmt.Sprintf("%s is not defined", e.names[0])
+	case e.isNil:
+		return fmt.Sprintf("%s is nil", parent)
+	}
+	return fmt.Sprintf("%s has no field %s", parent, e.names[e.at])
+}
+
+// describeMissingはエラーメッセージ用に解決できなかった参照を説明する
+// 先頭の名前がない場合は参照だけ、途中で辿れなくなった場合はその理由も付け加える
+func describeMissing(path string, err error) string {
+	if pe, ok := err.(*pathError); ok && pe.at > 0 {
+		return fmt.Sprintf("%s (%s)", path, pe.Error())
+	}
+	return path
+}
+
+func isNil(v interface{}) bool {
+	if v == nil {
+		return true
+	}
+	rv := reflect.ValueOf(v)
+	switch rv.Kind() {
+	case reflect.Ptr, reflect.Interface, reflect.Map, reflect.Slice:
+		return rv.IsNil()
+	}
+	return false
+}